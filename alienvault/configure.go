@@ -1,38 +1,151 @@
 package alienvault
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"strconv"
+	"time"
 
-	"github.com/form3tech-oss/alienvault"
-	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
-func providerConfigure(d *schema.ResourceData) (interface{}, error) {
-	version, ok := d.Get("api_version").(int)
-	if !ok {
-		if versionStr, ok := d.Get("api_version").(string); ok && versionStr != "" {
-			var err error
-			version, err = strconv.Atoi(versionStr)
+func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+
+	// settings in the provider block or environment take precedence over those in the selected profile
+	p, err := loadProfile(d.Get("shared_credentials_file").(string), d.Get("profile").(string))
+	if err != nil {
+		return nil, attributeError("profile", err)
+	}
+
+	setting := func(key string) string {
+		if v := d.Get(key).(string); v != "" {
+			return v
+		}
+		return p[key]
+	}
+
+	version := d.Get("api_version").(int)
+	if version == 0 && p["api_version"] != "" {
+		if version, err = strconv.Atoi(p["api_version"]); err != nil {
+			return nil, attributeError("profile", fmt.Errorf("invalid api_version in profile: %s", p["api_version"]))
+		}
+	}
+	skipTLSVerify := d.Get("skip_tls_verify").(bool) || p["skip_tls_verify"] == "true" || p["skip_tls_verify"] == "1"
+	readOnly := d.Get("read_only").(bool) || p["read_only"] == "true" || p["read_only"] == "1"
+
+	// credentials are validated when the client first authenticates, as they may not be known until then
+	creds := alienvault.Credentials{
+		Username:     d.Get("username").(string),
+		Password:     d.Get("password").(string),
+		ClientID:     d.Get("client_id").(string),
+		ClientSecret: d.Get("client_secret").(string),
+	}
+
+	profileCreds := alienvault.Credentials{
+		Username:     p["username"],
+		Password:     p["password"],
+		ClientID:     p["client_id"],
+		ClientSecret: p["client_secret"],
+	}
+
+	options := []alienvault.Option{}
+
+	if command := setting("credential_process"); command != "" {
+		// like the login itself, the process is only run once the client is used, so validate never runs it
+		blockCreds := creds
+		options = append(options, alienvault.WithCredentialsFunc(func(ctx context.Context) (alienvault.Credentials, error) {
+			processCreds, err := runCredentialProcess(ctx, command)
 			if err != nil {
-				return nil, fmt.Errorf("invalid version: %s", versionStr)
+				return alienvault.Credentials{}, err
 			}
-		}
+			return mergeCredentials(mergeCredentials(blockCreds, processCreds), profileCreds), nil
+		}))
 	}
 
-	client := alienvault.New(
-		d.Get("fqdn").(string),
-		alienvault.Credentials{
-			Username: d.Get("username").(string),
-			Password: d.Get("password").(string),
-		},
-		d.Get("skip_tls_verify").(bool),
-		version,
+	creds = mergeCredentials(creds, profileCreds)
+
+	tlsOptions := alienvault.TLSOptions{}
+
+	if tlsOptions.CACertPEM, err = readPEM(d, "ca_cert_pem", setting("ca_cert_file")); err != nil {
+		return nil, attributeError("ca_cert_file", err)
+	}
+	if tlsOptions.ClientCertPEM, err = readPEM(d, "client_cert_pem", setting("client_cert_file")); err != nil {
+		return nil, attributeError("client_cert_file", err)
+	}
+	if tlsOptions.ClientKeyPEM, err = readPEM(d, "client_key_pem", setting("client_key_file")); err != nil {
+		return nil, attributeError("client_key_file", err)
+	}
+
+	if (len(tlsOptions.ClientCertPEM) == 0) != (len(tlsOptions.ClientKeyPEM) == 0) {
+		return nil, diag.Errorf("a client certificate and key must be provided together")
+	}
+
+	// the client logs in on first use rather than here, so that validate and plans which don't touch AlienVault
+	// resources still work if it is unreachable, or if the fqdn is not known yet
+	options = append(options,
+		alienvault.WithRetryPolicy(alienvault.RetryPolicy{
+			MaxRetries: d.Get("max_retries").(int),
+			MinWait:    time.Second * time.Duration(d.Get("retry_min_wait").(int)),
+			MaxWait:    time.Second * time.Duration(d.Get("retry_max_wait").(int)),
+		}),
+		alienvault.WithRateLimit(d.Get("requests_per_second").(float64)),
+		alienvault.WithMaxConcurrentRequests(d.Get("max_concurrent_requests").(int)),
+		alienvault.WithListCache(time.Second*time.Duration(d.Get("cache_ttl").(int))),
+		alienvault.WithTLSOptions(tlsOptions),
+		alienvault.WithProxy(setting("proxy_url")),
+		alienvault.WithReadOnly(readOnly),
+		alienvault.WithOwnershipTag(setting("ownership_tag")),
 	)
 
-	if err := client.Authenticate(); err != nil {
-		return nil, fmt.Errorf("failed in authenticate: %w", err)
+	return alienvault.New(setting("fqdn"), creds, skipTLSVerify, version, options...), nil
+}
+
+// attributeError returns an error diagnostic pointing at the given provider argument
+func attributeError(attribute string, err error) diag.Diagnostics {
+	return diag.Diagnostics{
+		{
+			Severity:      diag.Error,
+			Summary:       err.Error(),
+			AttributePath: cty.GetAttrPath(attribute),
+		},
+	}
+}
+
+// readPEM returns PEM data provided either inline or via a file path
+func readPEM(d *schema.ResourceData, pemKey string, path string) ([]byte, error) {
+
+	if v, ok := d.GetOk(pemKey); ok {
+		return []byte(v.(string)), nil
 	}
 
-	return client, nil
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return data, nil
+	}
+
+	return nil, nil
+}
+
+// mergeCredentials fills any credentials missing from creds with those from fallback
+func mergeCredentials(creds alienvault.Credentials, fallback alienvault.Credentials) alienvault.Credentials {
+	if creds.Username == "" {
+		creds.Username = fallback.Username
+	}
+	if creds.Password == "" {
+		creds.Password = fallback.Password
+	}
+	if creds.ClientID == "" {
+		creds.ClientID = fallback.ClientID
+	}
+	if creds.ClientSecret == "" {
+		creds.ClientSecret = fallback.ClientSecret
+	}
+	return creds
 }
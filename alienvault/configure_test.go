@@ -1,13 +1,16 @@
 package alienvault
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
-	"github.com/form3tech-oss/alienvault"
-	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -18,40 +21,85 @@ func TestProviderConfigure(t *testing.T) {
 
 	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authCalled = true
+		if strings.HasSuffix(r.URL.Path, "/license") {
+			fmt.Fprint(w, `{"sensorNodesAllowed":2}`)
+		}
 	}))
 	defer ts.Close()
 
-	resourceSchema := map[string]*schema.Schema{
-		"fqdn": &schema.Schema{
-			Type: schema.TypeString,
-		},
-		"username": &schema.Schema{
-			Type: schema.TypeString,
-		},
-		"password": &schema.Schema{
-			Type: schema.TypeString,
-		},
-		"skip_tls_verify": &schema.Schema{
-			Type: schema.TypeBool,
-		},
-		"api_version": {
-				Type: schema.TypeInt,
-		},
-	}
 	resourceDataMap := map[string]interface{}{
-		"fqdn":     strings.Replace(ts.URL, "https://", "", -1),
-		"username": "something",
-		"password": "something",
+		"fqdn":            strings.Replace(ts.URL, "https://", "", -1),
+		"username":        "something",
+		"password":        "something",
 		"skip_tls_verify": "false",
 	}
-	resourceLocalData := schema.TestResourceDataRaw(t, resourceSchema, resourceDataMap)
+	resourceLocalData := schema.TestResourceDataRaw(t, Provider().Schema, resourceDataMap)
 
-	provider, err := providerConfigure(resourceLocalData)
-	require.Nil(t, err)
+	provider, diags := providerConfigure(context.Background(), resourceLocalData)
+	require.False(t, diags.HasError())
 
-	_, ok := provider.(*alienvault.Client)
+	client, ok := provider.(*alienvault.Client)
 	require.True(t, ok)
 
+	// authentication is deferred until the client is first used
+	assert.False(t, authCalled)
+
+	_, err := client.GetLicense()
+	require.Nil(t, err)
 	assert.True(t, authCalled)
 
 }
+
+func TestProviderConfigureWorksOffline(t *testing.T) {
+
+	resourceDataMap := map[string]interface{}{
+		"fqdn":        "127.0.0.1:1",
+		"username":    "something",
+		"password":    "something",
+		"max_retries": 0,
+	}
+	resourceLocalData := schema.TestResourceDataRaw(t, Provider().Schema, resourceDataMap)
+
+	provider, diags := providerConfigure(context.Background(), resourceLocalData)
+	require.False(t, diags.HasError())
+
+	_, err := provider.(*alienvault.Client).GetLicense()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "failed to authenticate")
+}
+
+func TestProviderConfigureRequiresCredentials(t *testing.T) {
+
+	resourceDataMap := map[string]interface{}{
+		"fqdn":      "127.0.0.1:1",
+		"client_id": "something",
+	}
+	resourceLocalData := schema.TestResourceDataRaw(t, Provider().Schema, resourceDataMap)
+
+	provider, diags := providerConfigure(context.Background(), resourceLocalData)
+	require.False(t, diags.HasError())
+
+	// missing credentials are reported against the first resource which needs them
+	_, err := provider.(*alienvault.Client).GetLicense()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "client ID and secret must be provided together")
+}
+
+func TestProviderConfigureReportsInvalidArguments(t *testing.T) {
+
+	resourceDataMap := map[string]interface{}{
+		"fqdn":         "127.0.0.1:1",
+		"username":     "something",
+		"password":     "something",
+		"ca_cert_file": "/this/does/not/exist.pem",
+	}
+	resourceLocalData := schema.TestResourceDataRaw(t, Provider().Schema, resourceDataMap)
+
+	_, diags := providerConfigure(context.Background(), resourceLocalData)
+	require.True(t, diags.HasError())
+	require.Equal(t, 1, len(diags))
+
+	// the error points at the argument which caused it
+	assert.Equal(t, cty.GetAttrPath("ca_cert_file"), diags[0].AttributePath)
+	assert.Contains(t, diags[0].Summary, "/this/does/not/exist.pem")
+}
@@ -0,0 +1,35 @@
+package alienvault
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+)
+
+// isNotFound returns true if the error shows the object no longer exists in AlienVault, meaning it should be removed
+// from state. Any other error (network, auth etc.) tells us nothing about the object, so it must not be dropped.
+func isNotFound(err error) bool {
+	var notFound *alienvault.NotFoundError
+	return errors.As(err, &notFound)
+}
+
+// isReadOnly returns true if the error was caused by the provider refusing to make a change in read-only mode
+func isReadOnly(err error) bool {
+	var readOnly *alienvault.ReadOnlyError
+	return errors.As(err, &readOnly)
+}
+
+// isTransient returns true unless the error shows that trying again would make no difference, i.e. AlienVault rejected
+// our credentials or the request itself. Network errors, 429s and 5xxs are assumed to clear up by themselves.
+func isTransient(err error) bool {
+	var authErr *alienvault.AuthError
+	if errors.As(err, &authErr) {
+		return false
+	}
+	var apiErr *alienvault.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return true
+}
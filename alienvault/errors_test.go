@@ -0,0 +1,31 @@
+package alienvault
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsNotFound(t *testing.T) {
+	assert.True(t, isNotFound(&alienvault.NotFoundError{Resource: "job", ID: "abc"}))
+	assert.True(t, isNotFound(fmt.Errorf("wrapped: %w", &alienvault.NotFoundError{Resource: "job", ID: "abc"})))
+	assert.False(t, isNotFound(&alienvault.APIError{StatusCode: 502}))
+	assert.False(t, isNotFound(&alienvault.AuthError{StatusCode: 401}))
+	assert.False(t, isNotFound(fmt.Errorf("job abc could not be found")))
+}
+
+func TestIsReadOnly(t *testing.T) {
+	assert.True(t, isReadOnly(&alienvault.ReadOnlyError{Resource: "sensor", Operation: "delete"}))
+	assert.True(t, isReadOnly(fmt.Errorf("wrapped: %w", &alienvault.ReadOnlyError{Resource: "sensor", Operation: "delete"})))
+	assert.False(t, isReadOnly(&alienvault.APIError{StatusCode: 403}))
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, isTransient(&alienvault.APIError{StatusCode: 502}))
+	assert.True(t, isTransient(&alienvault.APIError{StatusCode: 429}))
+	assert.True(t, isTransient(fmt.Errorf("connection refused")))
+	assert.False(t, isTransient(&alienvault.APIError{StatusCode: 400}))
+	assert.False(t, isTransient(fmt.Errorf("wrapped: %w", &alienvault.AuthError{StatusCode: 401})))
+}
@@ -0,0 +1,46 @@
+package alienvault
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+)
+
+// checkSensorLicense fails the plan if the license has expired, or has no room for the given number of sensors or
+// sensor keys once everything else planned is counted, so that a stack is never left half created. A transient failure
+// to check is only logged, as it is no reason to block a plan which may well succeed, but any other failure (such as
+// AlienVault rejecting our credentials) fails the plan, as the apply would fail the same way.
+func checkSensorLicense(ctx context.Context, m interface{}, count int, sweeps bool) error {
+
+	client, ok := m.(*alienvault.Client)
+	if !ok {
+		return nil
+	}
+
+	reservation, fits, err := client.ReserveSensorSlots(ctx, count, sweeps)
+	if err != nil {
+		if !isTransient(err) {
+			return fmt.Errorf("failed to check the sensor license: %w", err)
+		}
+		log.Printf("[WARN] failed to check the sensor license: %s", err)
+		return nil
+	}
+
+	if reservation.License.IsExpired() {
+		expiredAt := time.Unix(reservation.License.Expiration, 0).UTC().Format(time.RFC3339)
+		return fmt.Errorf("the AlienVault license expired at %s, so no sensors can be created", expiredAt)
+	}
+
+	if !fits {
+		room := reservation.License.SensorNodeLimit - reservation.InUse + reservation.Sweepable
+		if room < 0 {
+			room = 0
+		}
+		return fmt.Errorf("the AlienVault license has room for %d more sensors, counting existing sensors and unused sensor keys, which is not enough for the %d sensors and sensor keys planned", room, reservation.Reserved)
+	}
+
+	return nil
+}
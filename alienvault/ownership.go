@@ -0,0 +1,30 @@
+package alienvault
+
+import (
+	"log"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+)
+
+// adopt stamps an imported object with the provider's ownership tag, using the supplied update function, unless it
+// already carries it. Objects are left alone if no tag is configured, or the provider is in read-only mode.
+func adopt(client *alienvault.Client, resource string, id string, ownershipTag string, update func() error) error {
+
+	if client.OwnershipTag() == "" || client.Owns(ownershipTag) {
+		return nil
+	}
+
+	if ownershipTag != "" {
+		log.Printf("[WARN] %s %s is owned by %q, taking ownership", resource, id, ownershipTag)
+	}
+
+	if err := update(); err != nil {
+		if isReadOnly(err) {
+			log.Printf("[WARN] not stamping %s %s with the ownership tag as the provider is in read-only mode", resource, id)
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package alienvault
+
+import (
+	"testing"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdopt(t *testing.T) {
+
+	client := alienvault.New("example.com", alienvault.Credentials{}, true, 1, alienvault.WithOwnershipTag("mine"))
+
+	stamped := false
+	stamp := func() error {
+		stamped = true
+		return nil
+	}
+
+	require.Nil(t, adopt(client, "sensor", "abc", "", stamp))
+	assert.True(t, stamped)
+
+	stamped = false
+	require.Nil(t, adopt(client, "sensor", "abc", "other", stamp))
+	assert.True(t, stamped)
+
+	stamped = false
+	require.Nil(t, adopt(client, "sensor", "abc", "mine", stamp))
+	assert.False(t, stamped)
+
+	// there is nothing to stamp without a tag
+	untagged := alienvault.New("example.com", alienvault.Credentials{}, true, 1)
+	require.Nil(t, adopt(untagged, "sensor", "abc", "", stamp))
+	assert.False(t, stamped)
+
+	// and read-only mode is respected
+	readOnly := func() error {
+		return &alienvault.ReadOnlyError{Resource: "sensor", Operation: "update"}
+	}
+	require.Nil(t, adopt(client, "sensor", "abc", "", readOnly))
+}
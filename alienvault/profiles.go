@@ -0,0 +1,209 @@
+package alienvault
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultSharedCredentialsFile = "~/.alienvault/credentials"
+	defaultProfile               = "default"
+)
+
+// profile is a named set of provider settings read from the shared credentials file, keyed by provider argument name
+type profile map[string]string
+
+// parseProfiles reads profiles from the shared credentials file at path, which is either YAML or INI. Files ending in
+// .yaml or .yml are YAML and those ending in .ini are INI. Otherwise, a file whose first setting is a [profile] is INI,
+// and any other file is YAML.
+func parseProfiles(r io.Reader, path string) (map[string]profile, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return parseYAMLProfiles(data)
+	case ".ini":
+		return parseINIProfiles(data)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			return parseINIProfiles(data)
+		}
+		break
+	}
+
+	return parseYAMLProfiles(data)
+}
+
+// parseYAMLProfiles reads profiles from YAML, where each top level key is a profile containing a map of settings
+func parseYAMLProfiles(data []byte) (map[string]profile, error) {
+
+	var raw map[string]map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	profiles := map[string]profile{}
+	for name, settings := range raw {
+		profiles[name] = profile{}
+		for key, value := range settings {
+			switch value.(type) {
+			case string, bool, int, float64:
+				profiles[name][key] = fmt.Sprint(value)
+			case nil:
+			default:
+				return nil, fmt.Errorf("%s.%s: expected a single value", name, key)
+			}
+		}
+	}
+
+	return profiles, nil
+}
+
+// parseINIProfiles reads profiles from INI, where each [section] is a profile containing key = value settings
+func parseINIProfiles(data []byte) (map[string]profile, error) {
+
+	profiles := map[string]profile{}
+	var current profile
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			name := strings.TrimSpace(text[1 : len(text)-1])
+			if _, ok := profiles[name]; !ok {
+				profiles[name] = profile{}
+			}
+			current = profiles[name]
+			continue
+		}
+
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected a [profile] or key = value", line)
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: setting found outside of a [profile]", line)
+		}
+		current[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return profiles, scanner.Err()
+}
+
+// loadProfile returns the named profile from the shared credentials file at path. If no profile is named, the default
+// profile is used if it exists, and it is not an error for the file to be missing.
+func loadProfile(path string, name string) (profile, error) {
+
+	explicit := name != ""
+	if !explicit {
+		name = defaultProfile
+	}
+
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return profile{}, nil
+		}
+		return nil, fmt.Errorf("failed to read shared credentials file: %w", err)
+	}
+	defer f.Close()
+
+	profiles, err := parseProfiles(f, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse shared credentials file %s: %w", path, err)
+	}
+
+	p, ok := profiles[name]
+	if !ok {
+		if explicit {
+			return nil, fmt.Errorf("profile %q not found in %s", name, path)
+		}
+		return profile{}, nil
+	}
+
+	return p, nil
+}
+
+// expandHome replaces a leading ~ in the path with the current user's home directory
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// credentialProcessOutput is the JSON a credential_process command is expected to print
+type credentialProcessOutput struct {
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// runCredentialProcess runs the given command through the shell and parses the credentials it prints to stdout
+func runCredentialProcess(ctx context.Context, command string) (alienvault.Credentials, error) {
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return alienvault.Credentials{}, fmt.Errorf("credential_process failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	output := credentialProcessOutput{}
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return alienvault.Credentials{}, fmt.Errorf("credential_process did not print valid JSON: %w", err)
+	}
+
+	return alienvault.Credentials{
+		Username:     output.Username,
+		Password:     output.Password,
+		ClientID:     output.ClientID,
+		ClientSecret: output.ClientSecret,
+	}, nil
+}
@@ -0,0 +1,224 @@
+package alienvault
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/avtest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCredentialsFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "alienvault")
+	require.Nil(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "credentials")
+	require.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestParseProfiles(t *testing.T) {
+
+	profiles, err := parseProfiles(strings.NewReader(`
+# a comment
+[default]
+fqdn = default.alienvault.cloud
+
+; another comment
+[tenant-b]
+fqdn     = b.alienvault.cloud
+username = b@example.com
+password = pa=ss
+`), "credentials")
+	require.Nil(t, err)
+
+	assert.Equal(t, "default.alienvault.cloud", profiles["default"]["fqdn"])
+	assert.Equal(t, "b.alienvault.cloud", profiles["tenant-b"]["fqdn"])
+	assert.Equal(t, "b@example.com", profiles["tenant-b"]["username"])
+	assert.Equal(t, "pa=ss", profiles["tenant-b"]["password"])
+}
+
+func TestParseYAMLProfiles(t *testing.T) {
+
+	const content = `
+# a comment
+default:
+  fqdn: default.alienvault.cloud
+
+tenant-b:
+  fqdn: b.alienvault.cloud
+  username: b@example.com
+  password: "pa: ss"
+  skip_tls_verify: true
+  api_version: 2
+`
+
+	// YAML is recognised by its extension, or by not starting with a [profile]
+	for _, path := range []string{"credentials.yaml", "credentials.yml", "credentials"} {
+		t.Run(path, func(t *testing.T) {
+			profiles, err := parseProfiles(strings.NewReader(content), path)
+			require.Nil(t, err)
+
+			assert.Equal(t, "default.alienvault.cloud", profiles["default"]["fqdn"])
+			assert.Equal(t, "b.alienvault.cloud", profiles["tenant-b"]["fqdn"])
+			assert.Equal(t, "b@example.com", profiles["tenant-b"]["username"])
+			assert.Equal(t, "pa: ss", profiles["tenant-b"]["password"])
+			assert.Equal(t, "true", profiles["tenant-b"]["skip_tls_verify"])
+			assert.Equal(t, "2", profiles["tenant-b"]["api_version"])
+		})
+	}
+
+	_, err := parseProfiles(strings.NewReader("default:\n  fqdn: [a, b]\n"), "credentials.yaml")
+	require.NotNil(t, err)
+
+	_, err = parseProfiles(strings.NewReader(content), "credentials.ini")
+	require.NotNil(t, err)
+}
+
+func TestParseProfilesInvalid(t *testing.T) {
+
+	_, err := parseProfiles(strings.NewReader("fqdn = outside.alienvault.cloud\n"), "credentials")
+	require.NotNil(t, err)
+
+	_, err = parseProfiles(strings.NewReader("[default]\nnonsense\n"), "credentials")
+	require.NotNil(t, err)
+}
+
+func TestLoadProfile(t *testing.T) {
+
+	path := writeCredentialsFile(t, "[default]\nfqdn = default.alienvault.cloud\n[other]\nfqdn = other.alienvault.cloud\n")
+
+	p, err := loadProfile(path, "")
+	require.Nil(t, err)
+	assert.Equal(t, "default.alienvault.cloud", p["fqdn"])
+
+	p, err = loadProfile(path, "other")
+	require.Nil(t, err)
+	assert.Equal(t, "other.alienvault.cloud", p["fqdn"])
+
+	_, err = loadProfile(path, "missing")
+	require.NotNil(t, err)
+
+	// a missing file is only a problem if a profile was asked for
+	p, err = loadProfile(filepath.Join(filepath.Dir(path), "nope"), "")
+	require.Nil(t, err)
+	assert.Empty(t, p)
+
+	_, err = loadProfile(filepath.Join(filepath.Dir(path), "nope"), "other")
+	require.NotNil(t, err)
+}
+
+func TestProviderConfigureFromProfile(t *testing.T) {
+
+	var loginBody string
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/login"):
+			b, _ := ioutil.ReadAll(r.Body)
+			loginBody = string(b)
+		case strings.HasSuffix(r.URL.Path, "/license"):
+			fmt.Fprint(w, `{"sensorNodesAllowed":2}`)
+		}
+	}))
+	defer ts.Close()
+
+	path := writeCredentialsFile(t, fmt.Sprintf(`
+[tenant]
+fqdn            = %s
+username        = profile@example.com
+password        = profile-password
+skip_tls_verify = true
+`, strings.Replace(ts.URL, "https://", "", -1)))
+
+	resourceDataMap := map[string]interface{}{
+		"profile":                 "tenant",
+		"shared_credentials_file": path,
+		"password":                "override",
+	}
+	resourceLocalData := schema.TestResourceDataRaw(t, Provider().Schema, resourceDataMap)
+
+	provider, diags := providerConfigure(context.Background(), resourceLocalData)
+	require.False(t, diags.HasError())
+
+	_, err := provider.(*alienvault.Client).GetLicense()
+	require.Nil(t, err)
+
+	// the provider block takes precedence over the profile
+	assert.Contains(t, loginBody, `"email":"profile@example.com"`)
+	assert.Contains(t, loginBody, `"password":"override"`)
+}
+
+func TestRunCredentialProcess(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("test command requires a POSIX shell")
+	}
+
+	creds, err := runCredentialProcess(context.Background(), `echo '{"username":"process@example.com","password":"secret"}'`)
+	require.Nil(t, err)
+	assert.Equal(t, "process@example.com", creds.Username)
+	assert.Equal(t, "secret", creds.Password)
+
+	_, err = runCredentialProcess(context.Background(), `echo oops >&2; exit 1`)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "oops")
+
+	_, err = runCredentialProcess(context.Background(), `echo not json`)
+	require.NotNil(t, err)
+}
+
+func TestCredentialProcessRunsOnFirstAuthentication(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("test command requires a POSIX shell")
+	}
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	marker := filepath.Join(t.TempDir(), "ran")
+
+	resourceDataMap := map[string]interface{}{
+		"fqdn":               server.FQDN(),
+		"skip_tls_verify":    true,
+		"username":           avtest.Username,
+		"credential_process": fmt.Sprintf(`touch %q; echo '{"username":"ignored@example.com","password":%q}'`, marker, avtest.Password),
+	}
+	resourceLocalData := schema.TestResourceDataRaw(t, Provider().Schema, resourceDataMap)
+
+	provider, diags := providerConfigure(context.Background(), resourceLocalData)
+	require.False(t, diags.HasError())
+
+	_, err := os.Stat(marker)
+	assert.True(t, os.IsNotExist(err), "credential_process ran when the provider was configured")
+
+	// the username from the provider block takes precedence over the one from the process
+	_, err = provider.(*alienvault.Client).GetLicense()
+	require.Nil(t, err)
+
+	_, err = os.Stat(marker)
+	assert.Nil(t, err)
+}
+
+func TestMergeCredentials(t *testing.T) {
+
+	creds := mergeCredentials(
+		alienvault.Credentials{Username: "a"},
+		alienvault.Credentials{Username: "b", Password: "c", ClientID: "d"},
+	)
+
+	assert.Equal(t, alienvault.Credentials{Username: "a", Password: "c", ClientID: "d"}, creds)
+}
@@ -1,8 +1,10 @@
 package alienvault
 
 import (
-    "github.com/hashicorp/terraform/helper/schema"
+    "github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
     "os"
+    "time"
 )
 
 // Provider makes the AlienVault provider available
@@ -11,31 +13,46 @@ func Provider() *schema.Provider {
         Schema: map[string]*schema.Schema{
             "fqdn": {
                 Type:        schema.TypeString,
-                Required:    true,
+                Optional:    true,
                 Description: "The fully qualified domain name for your AlienVault instance e.g. example.alienvault.cloud",
                 DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_FQDN", nil),
                 Sensitive:   true,
             },
             "api_version": {
                 Type:        schema.TypeInt,
-                Description: "The api version you are using, normally 1 or 2",
-                DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_VERSION", 1),
+                Description: "Forces the API version used for sensors, 1 or 2. By default the version is detected automatically",
+                DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_VERSION", nil),
                 Optional:    true,
+                Deprecated:  "The API version for each endpoint is now detected automatically, so this can be removed",
             },
             "username": {
                 Type:        schema.TypeString,
-                Required:    true,
-                Description: "AV username",
+                Optional:    true,
+                Description: "AV username, used for endpoints which are only available in the internal API",
                 DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_USERNAME", nil),
                 Sensitive:   true,
             },
             "password": {
                 Type:        schema.TypeString,
-                Required:    true,
+                Optional:    true,
                 Description: "AV password",
                 DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_PASSWORD", nil),
                 Sensitive:   true,
             },
+            "client_id": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "OAuth2 client ID, used to obtain a bearer token for endpoints available in the public v2 API",
+                DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_CLIENT_ID", nil),
+                Sensitive:   true,
+            },
+            "client_secret": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "OAuth2 client secret",
+                DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_CLIENT_SECRET", nil),
+                Sensitive:   true,
+            },
             "skip_tls_verify": {
                 Type:        schema.TypeBool,
                 Optional:    true,
@@ -47,12 +64,135 @@ func Provider() *schema.Provider {
                     return false, nil
                 },
             },
+            "read_only": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                Description: "Refuse to make any changes in AlienVault. Any operation which would create, update or delete an object fails without sending a request",
+                DefaultFunc: func() (interface{}, error) {
+                    if v := os.Getenv("ALIENVAULT_READ_ONLY"); v != "" {
+                        return v == "true" || v == "1", nil
+                    }
+                    return false, nil
+                },
+            },
+            "ownership_tag": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "A tag identifying this workspace, which is added to the description of every sensor and job it creates. Dead sensors are only swept or deregistered if they carry this tag",
+                DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_OWNERSHIP_TAG", nil),
+            },
+            "profile": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "The name of a profile in the shared credentials file to take settings from. Defaults to the 'default' profile, if there is one",
+                DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_PROFILE", nil),
+            },
+            "shared_credentials_file": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "Path to the shared credentials file containing profiles",
+                DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_SHARED_CREDENTIALS_FILE", defaultSharedCredentialsFile),
+            },
+            "credential_process": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "A command which prints credentials as JSON to stdout, run the first time the provider needs to authenticate",
+                DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_CREDENTIAL_PROCESS", nil),
+            },
+            "max_retries": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                Description:  "The maximum number of times to retry a request which fails for transient reasons, such as a 5xx or 429 response",
+                DefaultFunc:  schema.EnvDefaultFunc("ALIENVAULT_MAX_RETRIES", alienvault.DefaultRetryPolicy.MaxRetries),
+                ValidateFunc: validateNonNegativeInt,
+            },
+            "retry_min_wait": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                Description:  "The number of seconds to wait before the first retry. This doubles for each subsequent retry, up to retry_max_wait",
+                DefaultFunc:  schema.EnvDefaultFunc("ALIENVAULT_RETRY_MIN_WAIT", int(alienvault.DefaultRetryPolicy.MinWait/time.Second)),
+                ValidateFunc: validateNonNegativeInt,
+            },
+            "retry_max_wait": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                Description:  "The maximum number of seconds to wait between retries. If the API asks for a longer wait via a Retry-After header, the request fails instead",
+                DefaultFunc:  schema.EnvDefaultFunc("ALIENVAULT_RETRY_MAX_WAIT", int(alienvault.DefaultRetryPolicy.MaxWait/time.Second)),
+                ValidateFunc: validateNonNegativeInt,
+            },
+            "requests_per_second": {
+                Type:         schema.TypeFloat,
+                Optional:     true,
+                Description:  "The maximum rate at which requests are sent to AlienVault. Defaults to 0, meaning unlimited",
+                DefaultFunc:  schema.EnvDefaultFunc("ALIENVAULT_REQUESTS_PER_SECOND", 0),
+                ValidateFunc: validateNonNegativeFloat,
+            },
+            "max_concurrent_requests": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                Description:  "The maximum number of requests in flight to AlienVault at once. Defaults to 0, meaning unlimited",
+                DefaultFunc:  schema.EnvDefaultFunc("ALIENVAULT_MAX_CONCURRENT_REQUESTS", 0),
+                ValidateFunc: validateNonNegativeInt,
+            },
+            "cache_ttl": {
+                Type:        schema.TypeInt,
+                Optional:    true,
+                Description: "Seconds to cache lists of jobs, sensors and sensor keys for, so refreshing many resources shares API calls. Defaults to 0, meaning no caching",
+                DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_CACHE_TTL", 0),
+            },
+            "ca_cert_file": {
+                Type:          schema.TypeString,
+                Optional:      true,
+                Description:   "Path to a PEM bundle of CA certificates to trust, in addition to the system roots",
+                DefaultFunc:   schema.EnvDefaultFunc("ALIENVAULT_CA_CERT_FILE", nil),
+                ConflictsWith: []string{"ca_cert_pem"},
+            },
+            "ca_cert_pem": {
+                Type:          schema.TypeString,
+                Optional:      true,
+                Description:   "A PEM bundle of CA certificates to trust, in addition to the system roots",
+                ConflictsWith: []string{"ca_cert_file"},
+            },
+            "client_cert_file": {
+                Type:          schema.TypeString,
+                Optional:      true,
+                Description:   "Path to a PEM encoded client certificate to present",
+                DefaultFunc:   schema.EnvDefaultFunc("ALIENVAULT_CLIENT_CERT_FILE", nil),
+                ConflictsWith: []string{"client_cert_pem"},
+            },
+            "client_key_file": {
+                Type:          schema.TypeString,
+                Optional:      true,
+                Description:   "Path to the PEM encoded private key for the client certificate",
+                DefaultFunc:   schema.EnvDefaultFunc("ALIENVAULT_CLIENT_KEY_FILE", nil),
+                ConflictsWith: []string{"client_key_pem"},
+            },
+            "client_cert_pem": {
+                Type:          schema.TypeString,
+                Optional:      true,
+                Description:   "A PEM encoded client certificate to present",
+                ConflictsWith: []string{"client_cert_file"},
+            },
+            "client_key_pem": {
+                Type:          schema.TypeString,
+                Optional:      true,
+                Description:   "The PEM encoded private key for the client certificate",
+                Sensitive:     true,
+                ConflictsWith: []string{"client_key_file"},
+            },
+            "proxy_url": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "URL of a proxy to send all requests through, including those to sensor appliances. Hosts listed in NO_PROXY are not proxied. Defaults to the HTTPS_PROXY/HTTP_PROXY environment variables",
+                DefaultFunc: schema.EnvDefaultFunc("ALIENVAULT_PROXY_URL", nil),
+            },
         },
         ResourcesMap: map[string]*schema.Resource{
             "alienvault_job_aws_bucket":     resourceJobAWSBucket(),
             "alienvault_job_aws_cloudwatch": resourceJobAWSCloudWatch(),
             "alienvault_sensor":             resourceSensor(),
+            "alienvault_sensor_key":         resourceSensorKey(),
         },
-        ConfigureFunc: providerConfigure,
+        ConfigureContextFunc: providerConfigure,
     }
 }
@@ -1,14 +1,19 @@
 package alienvault
 
 import (
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 
-	"github.com/hashicorp/terraform/helper/schema"
-	"github.com/hashicorp/terraform/terraform"
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/avtest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
-var testAccProviders map[string]terraform.ResourceProvider
+var testAccProviderFactories map[string]func() (*schema.Provider, error)
 var testAccProvider *schema.Provider
 
 func TestProvider(t *testing.T) {
@@ -17,6 +22,79 @@ func TestProvider(t *testing.T) {
 	}
 }
 
+func TestProviderRejectsNegativeLimits(t *testing.T) {
+
+	for _, key := range []string{"max_retries", "retry_min_wait", "retry_max_wait", "requests_per_second", "max_concurrent_requests"} {
+		t.Run(key, func(t *testing.T) {
+			diags := Provider().Validate(terraform.NewResourceConfigRaw(map[string]interface{}{key: -1}))
+			if !diags.HasError() {
+				t.Fatalf("expected %s = -1 to be rejected", key)
+			}
+		})
+	}
+}
+
+// testProviderFactories returns factories for a fresh provider, for unit tests against a fake server
+func testProviderFactories() map[string]func() (*schema.Provider, error) {
+	return map[string]func() (*schema.Provider, error){
+		"alienvault": func() (*schema.Provider, error) {
+			return Provider(), nil
+		},
+	}
+}
+
+// testProviderConfig returns a provider block which talks to the given fake server, followed by the given config
+func testProviderConfig(server *avtest.Server, config string) string {
+	return fmt.Sprintf(`
+	provider "alienvault" {
+		fqdn            = %q
+		username        = %q
+		password        = %q
+		skip_tls_verify = true
+		ownership_tag   = "unit-test"
+	}
+	%s`, server.FQDN(), avtest.Username, avtest.Password, config)
+}
+
+// testCheckFakeJob runs check against the job held by the fake server for the named resource
+func testCheckFakeJob(server *avtest.Server, n string, check func(job avtest.Job) error) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("not found: %s", n)
+		}
+
+		job, ok := server.Job(rs.Primary.ID)
+		if !ok {
+			return fmt.Errorf("job %s does not exist", rs.Primary.ID)
+		}
+
+		return check(job)
+	}
+}
+
+// testCheckNoJobs checks the fake server holds no jobs
+func testCheckNoJobs(server *avtest.Server) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		if jobs := server.Jobs(); len(jobs) > 0 {
+			return fmt.Errorf("%d jobs still exist", len(jobs))
+		}
+		return nil
+	}
+}
+
+// testJobsGoneBeforeDelete makes the fake server respond to deletes of jobs as if they had already been deleted outside
+// of Terraform, after the last refresh
+func testJobsGoneBeforeDelete(server *avtest.Server) {
+	server.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != "DELETE" || !strings.HasPrefix(r.URL.Path, "/api/1.0/scheduler/") {
+			return false
+		}
+		http.NotFound(w, r)
+		return true
+	})
+}
+
 func testAccPreCheck(t *testing.T) {
 
 	required := []string{
@@ -34,9 +112,11 @@ func testAccPreCheck(t *testing.T) {
 
 func init() {
 	_ = os.Setenv("ALIENVAULT_SKIP_TLS_VERIFY", "1")
-	_ = os.Setenv("ALIENVAULT_VERSION", "2")
 	testAccProvider = Provider()
-	testAccProviders = map[string]terraform.ResourceProvider{
-		"alienvault": testAccProvider,
+	// checks use the configured client of testAccProvider, so every step must share the same instance
+	testAccProviderFactories = map[string]func() (*schema.Provider, error){
+		"alienvault": func() (*schema.Provider, error) {
+			return testAccProvider, nil
+		},
 	}
 }
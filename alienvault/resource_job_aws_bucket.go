@@ -1,21 +1,29 @@
 package alienvault
 
 import (
-	"fmt"
+	"context"
+	"log"
 
-	"github.com/form3tech-oss/alienvault"
-	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 func resourceJobAWSBucket() *schema.Resource {
 
 	return &schema.Resource{
-		Create: resourceJobAWSBucketCreate,
-		Read:   resourceJobAWSBucketRead,
-		Update: resourceJobAWSBucketUpdate,
-		Delete: resourceJobAWSBucketDelete,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(defaultTimeout),
+			Read:   schema.DefaultTimeout(defaultTimeout),
+			Update: schema.DefaultTimeout(defaultTimeout),
+			Delete: schema.DefaultTimeout(defaultTimeout),
+		},
+		CreateContext: resourceJobAWSBucketCreate,
+		ReadContext:   resourceJobAWSBucketRead,
+		UpdateContext: resourceJobAWSBucketUpdate,
+		DeleteContext: resourceJobAWSBucketDelete,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			StateContext: resourceJobAWSBucketImport,
 		},
 		Schema: map[string]*schema.Schema{
 			"sensor": &schema.Schema{
@@ -71,45 +79,52 @@ func resourceJobAWSBucket() *schema.Resource {
 	}
 }
 
-func resourceJobAWSBucketCreate(d *schema.ResourceData, m interface{}) error {
+func resourceJobAWSBucketCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 
 	client := m.(*alienvault.Client)
 
 	job := expandJobAWSBucket(d)
-	if err := client.CreateAWSBucketJob(job); err != nil {
-		return err
+	if err := client.CreateAWSBucketJobWithContext(ctx, job); err != nil {
+		return diag.FromErr(err)
 	}
 
 	if job.UUID == "" {
-		return fmt.Errorf("Failed to determine UUID of created resource")
+		return diag.Errorf("Failed to determine UUID of created resource")
 	}
 
 	d.SetId(job.UUID)
-	return resourceJobAWSBucketRead(d, m)
+	return resourceJobAWSBucketRead(ctx, d, m)
 }
 
-func resourceJobAWSBucketRead(d *schema.ResourceData, m interface{}) error {
-	job, err := m.(*alienvault.Client).GetAWSBucketJob(d.Id())
+func resourceJobAWSBucketRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	job, err := m.(*alienvault.Client).GetAWSBucketJobWithContext(ctx, d.Id())
 	if err != nil {
-		d.SetId("")
-		return err
+		if isNotFound(err) {
+			log.Printf("[WARN] job %s no longer exists, removing from state", d.Id())
+			d.SetId("")
+			return nil
+		}
+		return diag.FromErr(err)
 	}
-	return flattenJobAWSBucket(job, d)
+	return diag.FromErr(flattenJobAWSBucket(job, d))
 }
 
-func resourceJobAWSBucketUpdate(d *schema.ResourceData, m interface{}) error {
+func resourceJobAWSBucketUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 
 	job := expandJobAWSBucket(d)
-	if err := m.(*alienvault.Client).UpdateAWSBucketJob(job); err != nil {
-		return err
+	if err := m.(*alienvault.Client).UpdateAWSBucketJobWithContext(ctx, job); err != nil {
+		return diag.FromErr(err)
 	}
 
-	return resourceJobAWSBucketRead(d, m)
+	return resourceJobAWSBucketRead(ctx, d, m)
 }
 
-func resourceJobAWSBucketDelete(d *schema.ResourceData, m interface{}) error {
+func resourceJobAWSBucketDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	job := expandJobAWSBucket(d)
-	return m.(*alienvault.Client).DeleteAWSBucketJob(job)
+	if err := m.(*alienvault.Client).DeleteAWSBucketJobWithContext(ctx, job); err != nil && !isNotFound(err) {
+		return diag.FromErr(err)
+	}
+	return nil
 }
 
 func flattenJobAWSBucket(job *alienvault.AWSBucketJob, d *schema.ResourceData) error {
@@ -187,3 +202,22 @@ func translateScheduleToTF(schedule alienvault.JobSchedule) string {
 
 	return string(schedule)
 }
+
+// resourceJobAWSBucketImport adopts an existing job, stamping it with the provider's ownership tag
+func resourceJobAWSBucketImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+
+	client := m.(*alienvault.Client)
+
+	job, err := client.GetAWSBucketJobWithContext(ctx, d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := adopt(client, "job", d.Id(), job.OwnershipTag, func() error {
+		return client.UpdateAWSBucketJobWithContext(ctx, job)
+	}); err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
@@ -3,14 +3,14 @@ package alienvault
 import (
 	"errors"
 	"fmt"
-	"strings"
 	"testing"
 	"time"
 
-	"github.com/form3tech-oss/alienvault"
-	"github.com/hashicorp/terraform/helper/acctest"
-	"github.com/hashicorp/terraform/helper/resource"
-	"github.com/hashicorp/terraform/terraform"
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/avtest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 const testAccJobAWSBucketConfig = `
@@ -29,10 +29,10 @@ func TestAccResourceJobAWSBucket(t *testing.T) {
 	jobName := fmt.Sprintf("test-e2e-bucket-%d-%s", time.Now().UnixNano(), acctest.RandStringFromCharSet(10, acctest.CharSetAlphaNum))
 
 	resource.Test(t, resource.TestCase{
-		PreCheck:      func() { testAccPreCheck(t) },
-		IDRefreshName: "alienvault_job_aws_bucket.test-e2e-bucket-job",
-		Providers:     testAccProviders,
-		CheckDestroy:  testAccCheckJobAWSBucketDestroy,
+		PreCheck:          func() { testAccPreCheck(t) },
+		IDRefreshName:     "alienvault_job_aws_bucket.test-e2e-bucket-job",
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      testAccCheckJobAWSBucketDestroy,
 		Steps: []resource.TestStep{
 			{
 				Config: fmt.Sprintf(testAccJobAWSBucketConfig, jobName),
@@ -67,7 +67,7 @@ func testAccCheckJobAWSBucketDestroy(s *terraform.State) error {
 			return fmt.Errorf("job %q still exists", rs.Primary.ID)
 		}
 
-		if !strings.Contains(err.Error(), "could not be found") {
+		if !isNotFound(err) {
 			return fmt.Errorf("Unexpected error when checking for existence of job: %s", err)
 		}
 	}
@@ -134,3 +134,82 @@ func testAccCheckJobAWSBucketExists(n string, res *alienvault.AWSBucketJob) reso
 		return nil
 	}
 }
+
+const testJobAWSBucketConfig = `
+	resource "alienvault_job_aws_bucket" "test" {
+		name          = "bucket-job"
+		description   = "%s"
+		sensor        = "my-sensor"
+		schedule      = "%s"
+		bucket        = "my-bucket"
+		path          = "/logs"
+		source_format = "raw"
+		plugin        = "PostgreSQL"
+	}`
+
+func TestResourceJobAWSBucket(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		CheckDestroy:      testCheckNoJobs(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(server, fmt.Sprintf(testJobAWSBucketConfig, "first", "hourly")),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("alienvault_job_aws_bucket.test", "schedule", "hourly"),
+					testCheckFakeJob(server, "alienvault_job_aws_bucket.test", func(job avtest.Job) error {
+						params := job["params"].(map[string]interface{})
+						if job["action"] != string(alienvault.JobActionMonitorBucket) || params["bucketName"] != "my-bucket" || params["path"] != "/logs" {
+							return fmt.Errorf("unexpected job: %v", job)
+						}
+						if job["schedule"] != string(alienvault.JobScheduleHourly) || job["description"] != "first [terraform:unit-test]" {
+							return fmt.Errorf("unexpected job: %v", job)
+						}
+						return nil
+					}),
+				),
+			},
+			{
+				Config: testProviderConfig(server, fmt.Sprintf(testJobAWSBucketConfig, "second", "daily")),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("alienvault_job_aws_bucket.test", "description", "second"),
+					testCheckFakeJob(server, "alienvault_job_aws_bucket.test", func(job avtest.Job) error {
+						if job["schedule"] != string(alienvault.JobScheduleDaily) || job["description"] != "second [terraform:unit-test]" {
+							return fmt.Errorf("job was not updated: %v", job)
+						}
+						return nil
+					}),
+				),
+			},
+			{
+				ResourceName:      "alienvault_job_aws_bucket.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestResourceJobAWSBucketAlreadyDeleted(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(server, fmt.Sprintf(testJobAWSBucketConfig, "first", "hourly")),
+			},
+			{
+				// a job deleted after it was refreshed is already gone, which is all destroy wants
+				PreConfig: func() { testJobsGoneBeforeDelete(server) },
+				Config:    testProviderConfig(server, fmt.Sprintf(testJobAWSBucketConfig, "first", "hourly")),
+				Destroy:   true,
+			},
+		},
+	})
+}
@@ -1,21 +1,29 @@
 package alienvault
 
 import (
-	"fmt"
+	"context"
+	"log"
 
-	"github.com/form3tech-oss/alienvault"
-	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 func resourceJobAWSCloudWatch() *schema.Resource {
 
 	return &schema.Resource{
-		Create: resourceJobAWSCloudWatchCreate,
-		Read:   resourceJobAWSCloudWatchRead,
-		Update: resourceJobAWSCloudWatchUpdate,
-		Delete: resourceJobAWSCloudWatchDelete,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(defaultTimeout),
+			Read:   schema.DefaultTimeout(defaultTimeout),
+			Update: schema.DefaultTimeout(defaultTimeout),
+			Delete: schema.DefaultTimeout(defaultTimeout),
+		},
+		CreateContext: resourceJobAWSCloudWatchCreate,
+		ReadContext:   resourceJobAWSCloudWatchRead,
+		UpdateContext: resourceJobAWSCloudWatchUpdate,
+		DeleteContext: resourceJobAWSCloudWatchDelete,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			StateContext: resourceJobAWSCloudWatchImport,
 		},
 		Schema: map[string]*schema.Schema{
 			"sensor": &schema.Schema{
@@ -79,46 +87,53 @@ func resourceJobAWSCloudWatch() *schema.Resource {
 	}
 }
 
-func resourceJobAWSCloudWatchCreate(d *schema.ResourceData, m interface{}) error {
+func resourceJobAWSCloudWatchCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 
 	client := m.(*alienvault.Client)
 
 	job := expandJobAWSCloudWatch(d)
 
-	if err := client.CreateAWSCloudWatchJob(job); err != nil {
-		return err
+	if err := client.CreateAWSCloudWatchJobWithContext(ctx, job); err != nil {
+		return diag.FromErr(err)
 	}
 
 	if job.UUID == "" {
-		return fmt.Errorf("Failed to determine UUID of created resource")
+		return diag.Errorf("Failed to determine UUID of created resource")
 	}
 
 	d.SetId(job.UUID)
-	return resourceJobAWSCloudWatchRead(d, m)
+	return resourceJobAWSCloudWatchRead(ctx, d, m)
 }
 
-func resourceJobAWSCloudWatchRead(d *schema.ResourceData, m interface{}) error {
-	job, err := m.(*alienvault.Client).GetAWSCloudWatchJob(d.Id())
+func resourceJobAWSCloudWatchRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	job, err := m.(*alienvault.Client).GetAWSCloudWatchJobWithContext(ctx, d.Id())
 	if err != nil {
-		d.SetId("")
-		return err
+		if isNotFound(err) {
+			log.Printf("[WARN] job %s no longer exists, removing from state", d.Id())
+			d.SetId("")
+			return nil
+		}
+		return diag.FromErr(err)
 	}
-	return flattenJobAWSCloudWatch(job, d, m.(*alienvault.Client))
+	return diag.FromErr(flattenJobAWSCloudWatch(job, d, m.(*alienvault.Client)))
 }
 
-func resourceJobAWSCloudWatchUpdate(d *schema.ResourceData, m interface{}) error {
+func resourceJobAWSCloudWatchUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 
 	job := expandJobAWSCloudWatch(d)
-	if err := m.(*alienvault.Client).UpdateAWSCloudWatchJob(job); err != nil {
-		return err
+	if err := m.(*alienvault.Client).UpdateAWSCloudWatchJobWithContext(ctx, job); err != nil {
+		return diag.FromErr(err)
 	}
 
-	return resourceJobAWSCloudWatchRead(d, m)
+	return resourceJobAWSCloudWatchRead(ctx, d, m)
 }
 
-func resourceJobAWSCloudWatchDelete(d *schema.ResourceData, m interface{}) error {
+func resourceJobAWSCloudWatchDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	job := expandJobAWSCloudWatch(d)
-	return m.(*alienvault.Client).DeleteAWSCloudWatchJob(job)
+	if err := m.(*alienvault.Client).DeleteAWSCloudWatchJobWithContext(ctx, job); err != nil && !isNotFound(err) {
+		return diag.FromErr(err)
+	}
+	return nil
 }
 
 func flattenJobAWSCloudWatch(job *alienvault.AWSCloudWatchJob, d *schema.ResourceData, client *alienvault.Client) error {
@@ -171,3 +186,22 @@ func expandJobAWSCloudWatch(d *schema.ResourceData) *alienvault.AWSCloudWatchJob
 
 	return job
 }
+
+// resourceJobAWSCloudWatchImport adopts an existing job, stamping it with the provider's ownership tag
+func resourceJobAWSCloudWatchImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+
+	client := m.(*alienvault.Client)
+
+	job, err := client.GetAWSCloudWatchJobWithContext(ctx, d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := adopt(client, "job", d.Id(), job.OwnershipTag, func() error {
+		return client.UpdateAWSCloudWatchJobWithContext(ctx, job)
+	}); err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
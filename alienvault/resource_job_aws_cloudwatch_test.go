@@ -3,14 +3,14 @@ package alienvault
 import (
 	"errors"
 	"fmt"
-	"strings"
 	"testing"
 	"time"
 
-	"github.com/form3tech-oss/alienvault"
-	"github.com/hashicorp/terraform/helper/acctest"
-	"github.com/hashicorp/terraform/helper/resource"
-	"github.com/hashicorp/terraform/terraform"
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/avtest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 const testAccJobAWSCloudWatchConfig = `
@@ -30,10 +30,10 @@ func TestAccResourceJobAWSCloudWatch(t *testing.T) {
 	jobName := fmt.Sprintf("test-e2e-cloudwatch-%d-%s", time.Now().UnixNano(), acctest.RandStringFromCharSet(10, acctest.CharSetAlphaNum))
 
 	resource.Test(t, resource.TestCase{
-		PreCheck:      func() { testAccPreCheck(t) },
-		IDRefreshName: "alienvault_job_aws_cloudwatch.test-e2e-cloudwatch-job",
-		Providers:     testAccProviders,
-		CheckDestroy:  testAccCheckJobAWSCloudWatchDestroy,
+		PreCheck:          func() { testAccPreCheck(t) },
+		IDRefreshName:     "alienvault_job_aws_cloudwatch.test-e2e-cloudwatch-job",
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      testAccCheckJobAWSCloudWatchDestroy,
 		Steps: []resource.TestStep{
 			{
 				Config: fmt.Sprintf(testAccJobAWSCloudWatchConfig, jobName),
@@ -69,7 +69,7 @@ func testAccCheckJobAWSCloudWatchDestroy(s *terraform.State) error {
 			return fmt.Errorf("job %q still exists", rs.Primary.ID)
 		}
 
-		if !strings.Contains(err.Error(), "could not be found") {
+		if !isNotFound(err) {
 			return fmt.Errorf("Unexpected error when checking for existence of job: %s", err)
 		}
 	}
@@ -136,3 +136,81 @@ func testAccCheckJobAWSCloudWatchExists(n string, res *alienvault.AWSCloudWatchJ
 		return nil
 	}
 }
+
+const testJobAWSCloudWatchConfig = `
+	resource "alienvault_job_aws_cloudwatch" "test" {
+		name          = "cloudwatch-job"
+		sensor        = "my-sensor"
+		schedule      = "daily"
+		region        = "eu-west-1"
+		group         = "my-group"
+		stream        = "%s"
+		source_format = "syslog"
+		plugin        = "PostgreSQL"
+		disabled      = %t
+	}`
+
+func TestResourceJobAWSCloudWatch(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		CheckDestroy:      testCheckNoJobs(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(server, fmt.Sprintf(testJobAWSCloudWatchConfig, "*", false)),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("alienvault_job_aws_cloudwatch.test", "stream", "*"),
+					testCheckFakeJob(server, "alienvault_job_aws_cloudwatch.test", func(job avtest.Job) error {
+						params := job["params"].(map[string]interface{})
+						if job["action"] != string(alienvault.JobActionMonitorCloudWatch) || params["regionName"] != "eu-west-1" || params["groupName"] != "my-group" || params["source"] != "syslog" {
+							return fmt.Errorf("unexpected job: %v", job)
+						}
+						return nil
+					}),
+				),
+			},
+			{
+				Config: testProviderConfig(server, fmt.Sprintf(testJobAWSCloudWatchConfig, "my-stream", true)),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("alienvault_job_aws_cloudwatch.test", "stream", "my-stream"),
+					testCheckFakeJob(server, "alienvault_job_aws_cloudwatch.test", func(job avtest.Job) error {
+						params := job["params"].(map[string]interface{})
+						if params["streamName"] != "my-stream" || job["disabled"] != true {
+							return fmt.Errorf("job was not updated: %v", job)
+						}
+						return nil
+					}),
+				),
+			},
+			{
+				ResourceName:      "alienvault_job_aws_cloudwatch.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestResourceJobAWSCloudWatchAlreadyDeleted(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(server, fmt.Sprintf(testJobAWSCloudWatchConfig, "*", false)),
+			},
+			{
+				// a job deleted after it was refreshed is already gone, which is all destroy wants
+				PreConfig: func() { testJobsGoneBeforeDelete(server) },
+				Config:    testProviderConfig(server, fmt.Sprintf(testJobAWSCloudWatchConfig, "*", false)),
+				Destroy:   true,
+			},
+		},
+	})
+}
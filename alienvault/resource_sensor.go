@@ -3,13 +3,33 @@ package alienvault
 import (
 	"context"
 	"fmt"
+	"log"
 	"net"
 	"time"
 
-	"github.com/form3tech-oss/alienvault"
-	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
+// The ways in which a sensor whose appliance has lost connection to AlienVault can be handled
+const (
+	connectionLostError      = "error"      // fail the plan, leaving the sensor alone
+	connectionLostRecreate   = "recreate"   // plan to replace the sensor
+	connectionLostIgnore     = "ignore"     // carry on as if the sensor was healthy
+	connectionLostDeregister = "deregister" // deregister the sensor as soon as it is refreshed
+)
+
+var connectionLostActions = []string{connectionLostError, connectionLostRecreate, connectionLostIgnore, connectionLostDeregister}
+
+// The ways in which a sensor appliance can be activated
+const (
+	activationModeAppliance = "appliance" // the provider activates the appliance at the given IP
+	activationModeExternal  = "external"  // the appliance activates itself with the activation code, e.g. from its user data
+)
+
+var activationModes = []string{activationModeAppliance, activationModeExternal}
+
 func resourceSensor() *schema.Resource {
 
 	// create time has to take into account the time for the sensor appliance
@@ -19,13 +39,25 @@ func resourceSensor() *schema.Resource {
 	return &schema.Resource{
 		Timeouts: &schema.ResourceTimeout{
 			Create: &createTime,
+			Read:   schema.DefaultTimeout(defaultTimeout),
+			Update: schema.DefaultTimeout(defaultTimeout),
+			Delete: schema.DefaultTimeout(defaultTimeout),
 		},
-		Create: resourceSensorCreate,
-		Update: resourceSensorUpdate,
-		Read:   resourceSensorRead,
-		Delete: resourceSensorDelete,
+		CreateContext: resourceSensorCreate,
+		UpdateContext: resourceSensorUpdate,
+		ReadContext:   resourceSensorRead,
+		DeleteContext: resourceSensorDelete,
+		CustomizeDiff: resourceSensorCustomizeDiff,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			StateContext: resourceSensorImport,
+		},
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    resourceSensorV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: upgradeSensorStateV0,
+			},
 		},
 		Schema: map[string]*schema.Schema{
 			"name": &schema.Schema{
@@ -41,8 +73,8 @@ func resourceSensor() *schema.Resource {
 			},
 			"ip": &schema.Schema{
 				Type:         schema.TypeString,
-				Required:     true,
-				Description:  "The public IP address of the sensor",
+				Optional:     true,
+				Description:  "The public IP address of the sensor, which is required unless the sensor is activated externally",
 				ValidateFunc: validateIP,
 			},
 			"activation_code": &schema.Schema{
@@ -50,66 +82,326 @@ func resourceSensor() *schema.Resource {
 				Optional:     true,
 				Description:  "The activation code of the sensor",
 			},
+			"activation_mode": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "How the sensor appliance is activated: appliance, where the provider activates it via its IP, or external, where it activates itself with the activation code",
+				Default:      activationModeAppliance,
+				ValidateFunc: validateActivationMode,
+			},
+			"appliance_scheme": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "The scheme used to reach the appliance API, http or https",
+				Default:      "http",
+				ValidateFunc: validateApplianceScheme,
+			},
+			"appliance_port": &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Description:  "The port the appliance API listens on. Defaults to the standard port for appliance_scheme",
+				ValidateFunc: validatePort,
+			},
+			"appliance_ca_cert": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM encoded CA certificates to trust for the appliance API, in addition to those trusted for AlienVault",
+			},
+			"appliance_proxy": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "The URL of a proxy to reach the appliance API through, instead of the one used for AlienVault",
+				ValidateFunc: validateURL,
+			},
+			"bastion": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "An SSH bastion to tunnel requests to the appliance API through, for appliances which can't be reached directly",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"host": &schema.Schema{
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The address of the bastion",
+						},
+						"port": &schema.Schema{
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      22,
+							Description:  "The port SSH listens on",
+							ValidateFunc: validatePort,
+						},
+						"user": &schema.Schema{
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The user to authenticate as",
+						},
+						"private_key": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Sensitive:   true,
+							Description: "The PEM encoded private key to authenticate with",
+						},
+						"agent": &schema.Schema{
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Description: "Whether to authenticate with the keys held by the SSH agent at SSH_AUTH_SOCK",
+						},
+						"host_key": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The public key of the bastion in authorized_keys format. Required unless insecure_ignore_host_key is set",
+						},
+						"insecure_ignore_host_key": &schema.Schema{
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Whether to connect without verifying the identity of the bastion when no host_key is set. This is unsafe, as anyone able to intercept the connection can then read the sensor's activation details",
+						},
+					},
+				},
+			},
+			"poll_interval": &schema.Schema{
+				Type:             schema.TypeString,
+				Optional:         true,
+				Description:      "How long to wait between the first checks on the progress of sensor creation, as a duration such as 5s. The wait doubles after each check, up to max_poll_interval",
+				Default:          alienvault.DefaultPollPolicy.Interval.String(),
+				ValidateFunc:     validateDuration,
+				DiffSuppressFunc: suppressEquivalentDurations,
+			},
+			"max_poll_interval": &schema.Schema{
+				Type:             schema.TypeString,
+				Optional:         true,
+				Description:      "The longest wait between checks on the progress of sensor creation, as a duration such as 1m",
+				Default:          alienvault.DefaultPollPolicy.MaxInterval.String(),
+				ValidateFunc:     validateDuration,
+				DiffSuppressFunc: suppressEquivalentDurations,
+			},
+			"on_connection_lost": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "What to do when the sensor appliance loses connection to AlienVault: error, recreate, ignore or deregister",
+				Default:      connectionLostError,
+				ValidateFunc: validateConnectionLostAction,
+			},
+			"status": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The status of the sensor as reported by AlienVault, such as Ready or Connection lost",
+			},
+			"setup_status": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Whether the setup of the sensor has been completed",
+			},
+			"v1_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The ID of the sensor in the v1 API, which jobs refer to",
+			},
+			"v2_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The ID of the sensor in the v2 API",
+			},
+			"sensor_type": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The type of appliance the sensor runs on",
+			},
+			"version": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The version of the software running on the sensor appliance",
+			},
+			"last_connected": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The time the sensor appliance was last connected to AlienVault, in RFC 3339 format",
+			},
 		},
 	}
 }
 
-func resourceSensorCreate(d *schema.ResourceData, m interface{}) error {
+func resourceSensorCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 
 	client := m.(*alienvault.Client)
 
 	sensor := expandSensor(d)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
-	defer cancel()
-
-	ip := net.ParseIP(d.Get("ip").(string))
-	if ip == nil {
-		// this is a panic because it should never happen - the IP field will be ensured to be a valid IP by the schema ValidateFunc
-		panic("Failed to parse valid IP")
-	}
-
-	if err := client.CreateSensorViaAppliance(ctx, sensor, ip); err != nil {
-		return err
+	if d.Get("activation_mode").(string) == activationModeExternal {
+		if err := client.CreateSensorViaExternalActivation(ctx, sensor, expandPollPolicy(d)); err != nil {
+			return diag.FromErr(err)
+		}
+	} else {
+		if err := client.CreateSensorViaAppliance(ctx, sensor, expandApplianceEndpoint(d), expandPollPolicy(d)); err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
 	d.SetId(sensor.ID())
-	return resourceSensorRead(d, m)
+	return append(resourceSensorRead(ctx, d, m), sensorLicenseWarnings(ctx, client)...)
 }
 
-func resourceSensorUpdate(d *schema.ResourceData, m interface{}) error {
+func resourceSensorUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// everything else only affects how the provider manages the sensor, so is just saved to state
+	if !d.HasChanges("name", "description") {
+		return nil
+	}
 	client := m.(*alienvault.Client)
 	sensor := expandSensor(d)
-	return client.UpdateSensor(sensor)
+	return diag.FromErr(client.UpdateSensorWithContext(ctx, sensor))
 }
 
-func resourceSensorRead(d *schema.ResourceData, m interface{}) error {
-	sensor, err := m.(*alienvault.Client).GetSensor(d.Id())
+func resourceSensorRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	sensor, err := m.(*alienvault.Client).GetSensorWithContext(ctx, d.Id())
 	if err != nil {
-		d.SetId("")
-		return err
+		if isNotFound(err) {
+			log.Printf("[WARN] sensor %s no longer exists, removing from state", d.Id())
+			d.SetId("")
+			return nil
+		}
+		return diag.FromErr(err)
 	}
 	if sensor.Status == alienvault.SensorStatusConnectionLost {
-		d.SetId("")
-		if err := m.(*alienvault.Client).DeleteSensor(sensor); err != nil {
-			return err
+		log.Printf("[WARN] sensor %s has lost connection to AlienVault", d.Id())
+		if d.Get("on_connection_lost").(string) == connectionLostDeregister {
+			return deregisterSensor(ctx, d, m.(*alienvault.Client), sensor)
 		}
-		return fmt.Errorf("the sensor appliance lost communication with AlienVault - the sensor has been deregistered")
 	}
 	flattenSensor(sensor, d)
 	return nil
 }
 
-func resourceSensorDelete(d *schema.ResourceData, m interface{}) error {
+// deregisterSensor deletes a sensor which has lost connection to AlienVault and removes it from state, as long as it
+// was created by this provider
+func deregisterSensor(ctx context.Context, d *schema.ResourceData, client *alienvault.Client, sensor *alienvault.Sensor) diag.Diagnostics {
+	if !client.Owns(sensor.OwnershipTag) {
+		return diag.Errorf("the sensor appliance lost communication with AlienVault - the sensor was not deregistered as it does not carry this provider's ownership_tag")
+	}
+	d.SetId("")
+	if err := client.DeleteSensorWithContext(ctx, sensor); err != nil {
+		if isReadOnly(err) {
+			return diag.Errorf("the sensor appliance lost communication with AlienVault - the sensor was not deregistered as the provider is in read-only mode")
+		}
+		return diag.FromErr(err)
+	}
+	return diag.Errorf("the sensor appliance lost communication with AlienVault - the sensor has been deregistered")
+}
+
+// resourceSensorCustomizeDiff checks a new sensor can be created, and applies on_connection_lost to a sensor which has
+// lost connection to AlienVault. This is done when planning rather than refreshing, so that the configured action is
+// used rather than the one in state.
+func resourceSensorCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+
+	if d.Id() == "" {
+		if err := validateSensorActivation(d); err != nil {
+			return err
+		}
+		slots, sweeps := sensorSlots(d)
+		return checkSensorLicense(ctx, m, slots, sweeps)
+	}
+
+	if d.Get("status").(string) != string(alienvault.SensorStatusConnectionLost) {
+		return nil
+	}
+
+	switch d.Get("on_connection_lost").(string) {
+	case connectionLostRecreate:
+		if err := d.SetNew("status", string(alienvault.SensorStatusReady)); err != nil {
+			return err
+		}
+		return d.ForceNew("status")
+	case connectionLostError:
+		return fmt.Errorf("the sensor appliance lost communication with AlienVault - set on_connection_lost to recreate, ignore or deregister to handle this")
+	}
+
+	return nil
+}
+
+// validateSensorActivation checks a new sensor has what it needs to be activated in the configured activation_mode
+func validateSensorActivation(d *schema.ResourceDiff) error {
+
+	switch d.Get("activation_mode").(string) {
+	case activationModeExternal:
+		if d.NewValueKnown("activation_code") && d.Get("activation_code").(string) == "" {
+			return fmt.Errorf("an activation_code is required when activation_mode is %q, e.g. from an alienvault_sensor_key", activationModeExternal)
+		}
+	default:
+		if d.NewValueKnown("ip") && d.Get("ip").(string) == "" {
+			return fmt.Errorf("an ip is required when activation_mode is %q", activationModeAppliance)
+		}
+		if d.NewValueKnown("bastion.0.private_key") && d.Get("bastion.#").(int) > 0 && d.Get("bastion.0.private_key").(string) == "" && !d.Get("bastion.0.agent").(bool) {
+			return fmt.Errorf("either a private_key or agent is required to connect to the bastion")
+		}
+		if d.NewValueKnown("bastion.0.host_key") && d.Get("bastion.#").(int) > 0 && d.Get("bastion.0.host_key").(string) == "" && !d.Get("bastion.0.insecure_ignore_host_key").(bool) {
+			return fmt.Errorf("a host_key is required to verify the bastion, unless insecure_ignore_host_key is set")
+		}
+	}
+
+	return nil
+}
+
+// sensorSlots returns how many slots on the license a new sensor needs, and whether dead sensors are swept to make room
+// for it. A sensor given an activation_code takes the slot already held by its key, so needs none of its own.
+func sensorSlots(d *schema.ResourceDiff) (int, bool) {
+	if !d.NewValueKnown("activation_code") || d.Get("activation_code").(string) != "" {
+		return 0, false
+	}
+	return 1, d.Get("activation_mode").(string) == activationModeAppliance
+}
+
+func resourceSensorDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	sensor := expandSensor(d)
-	return m.(*alienvault.Client).DeleteSensor(sensor)
+	if err := m.(*alienvault.Client).DeleteSensorWithContext(ctx, sensor); err != nil && !isNotFound(err) {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+// sensorLicenseWarnings warns if the license has no room left for further sensors. Failing to check is not worth
+// failing the apply over, as the sensor has already been created.
+func sensorLicenseWarnings(ctx context.Context, client *alienvault.Client) diag.Diagnostics {
+
+	available, err := client.HasSensorAvailabilityWithContext(ctx)
+	if err != nil {
+		log.Printf("[WARN] failed to check the sensor license: %s", err)
+		return nil
+	}
+
+	if available {
+		return nil
+	}
+
+	return diag.Diagnostics{
+		{
+			Severity: diag.Warning,
+			Summary:  "AlienVault license nearly exhausted",
+			Detail:   "Every sensor allowed by the AlienVault license is now in use, so no further sensors can be created until one is removed or the license is upgraded.",
+		},
+	}
 }
 
 func flattenSensor(sensor *alienvault.Sensor, d *schema.ResourceData) {
 	d.SetId(sensor.ID())
 	d.Set("name", sensor.Name)
 	d.Set("description", sensor.Description)
-	d.Set("activation_code", sensor.ActivationCode)
+	// AlienVault doesn't return the activation code of a sensor once it has been used, so the one in config is kept
+	if sensor.ActivationCode != "" {
+		d.Set("activation_code", sensor.ActivationCode)
+	}
+	d.Set("status", string(sensor.Status))
+	d.Set("setup_status", string(sensor.SetupStatus))
+	d.Set("v1_id", sensor.V1ID)
+	d.Set("v2_id", sensor.V2ID)
+	d.Set("sensor_type", sensor.Type)
+	d.Set("version", sensor.Version)
+	if sensor.LastConnected > 0 {
+		d.Set("last_connected", time.Unix(sensor.LastConnected, 0).UTC().Format(time.RFC3339))
+	} else {
+		d.Set("last_connected", "")
+	}
 }
 
 func expandSensor(d *schema.ResourceData) *alienvault.Sensor {
@@ -123,3 +415,83 @@ func expandSensor(d *schema.ResourceData) *alienvault.Sensor {
 	}
 	return sensor
 }
+
+func expandApplianceEndpoint(d *schema.ResourceData) alienvault.ApplianceEndpoint {
+
+	ip := net.ParseIP(d.Get("ip").(string))
+	if ip == nil {
+		// this is a panic because it should never happen - the IP field will be ensured to be a valid IP by the schema ValidateFunc and CustomizeDiff
+		panic("Failed to parse valid IP")
+	}
+
+	endpoint := alienvault.ApplianceEndpoint{
+		IP:        ip,
+		Scheme:    d.Get("appliance_scheme").(string),
+		Port:      d.Get("appliance_port").(int),
+		CACertPEM: []byte(d.Get("appliance_ca_cert").(string)),
+		ProxyURL:  d.Get("appliance_proxy").(string),
+	}
+
+	if bastions := d.Get("bastion").([]interface{}); len(bastions) > 0 && bastions[0] != nil {
+		bastion := bastions[0].(map[string]interface{})
+		endpoint.Bastion = &alienvault.SSHBastion{
+			Host:          bastion["host"].(string),
+			Port:          bastion["port"].(int),
+			User:          bastion["user"].(string),
+			PrivateKeyPEM: []byte(bastion["private_key"].(string)),
+			UseAgent:      bastion["agent"].(bool),
+			HostKey:       bastion["host_key"].(string),
+
+			InsecureIgnoreHostKey: bastion["insecure_ignore_host_key"].(bool),
+		}
+	}
+
+	return endpoint
+}
+
+// suppressEquivalentDurations ignores changes between durations which are written differently but are the same, such as
+// 60s and 1m
+func suppressEquivalentDurations(k, old, new string, d *schema.ResourceData) bool {
+	oldDuration, err := time.ParseDuration(old)
+	if err != nil {
+		return false
+	}
+	newDuration, err := time.ParseDuration(new)
+	return err == nil && oldDuration == newDuration
+}
+
+// expandPollPolicy returns the policy for polling the progress of sensor creation. The durations are ensured to be valid
+// by the schema ValidateFunc, so a failure to parse one leaves it at its default.
+func expandPollPolicy(d *schema.ResourceData) alienvault.PollPolicy {
+	interval, _ := time.ParseDuration(d.Get("poll_interval").(string))
+	maxInterval, _ := time.ParseDuration(d.Get("max_poll_interval").(string))
+	return alienvault.PollPolicy{
+		Interval:    interval,
+		MaxInterval: maxInterval,
+	}
+}
+
+// resourceSensorImport adopts an existing sensor, stamping it with the provider's ownership tag
+func resourceSensorImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+
+	client := m.(*alienvault.Client)
+
+	sensor, err := client.GetSensorWithContext(ctx, d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := adopt(client, "sensor", d.Id(), sensor.OwnershipTag, func() error {
+		return client.UpdateSensorWithContext(ctx, sensor)
+	}); err != nil {
+		return nil, err
+	}
+
+	d.SetId(sensor.ID())
+	d.Set("on_connection_lost", connectionLostError)
+	d.Set("activation_mode", activationModeAppliance)
+	d.Set("appliance_scheme", "http")
+	d.Set("poll_interval", alienvault.DefaultPollPolicy.Interval.String())
+	d.Set("max_poll_interval", alienvault.DefaultPollPolicy.MaxInterval.String())
+	return []*schema.ResourceData{d}, nil
+}
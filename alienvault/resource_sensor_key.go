@@ -0,0 +1,160 @@
+package alienvault
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceSensorKey() *schema.Resource {
+
+	return &schema.Resource{
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(defaultTimeout),
+			Read:   schema.DefaultTimeout(defaultTimeout),
+			Delete: schema.DefaultTimeout(defaultTimeout),
+		},
+		CreateContext: resourceSensorKeyCreate,
+		ReadContext:   resourceSensorKeyRead,
+		DeleteContext: resourceSensorKeyDelete,
+		CustomizeDiff: resourceSensorKeyCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceSensorKeyImport,
+		},
+		Schema: map[string]*schema.Schema{
+			"key": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "The key, also known as the activation code, used to activate a sensor appliance",
+			},
+			"created_at": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The time the key was created, in RFC 3339 format",
+			},
+			"expires_at": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The time after which the key can no longer be used, in RFC 3339 format",
+			},
+			"consumed": &schema.Schema{
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the key has been used, or otherwise can no longer be used",
+			},
+			"node_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The ID of the sensor which was activated with the key, if any",
+			},
+		},
+	}
+}
+
+// sensorKeyID returns the ID of the resource for the given key. The key itself is a secret, and Terraform never hides
+// resource IDs, so a hash of the key is used instead.
+func sensorKeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func resourceSensorKeyCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+
+	key, err := m.(*alienvault.Client).CreateSensorKeyWithContext(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(sensorKeyID(key.ID))
+	flattenSensorKey(key, d)
+	return nil
+}
+
+func resourceSensorKeyRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+
+	key, err := m.(*alienvault.Client).GetSensorKeyWithContext(ctx, d.Get("key").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// a key which has disappeared is kept in state, so that it is replaced rather than silently created again
+	if key.Consumed && key.NodeID == nil {
+		log.Printf("[WARN] sensor key %s no longer exists", d.Id())
+		d.Set("consumed", true)
+		return nil
+	}
+
+	flattenSensorKey(key, d)
+	return nil
+}
+
+func resourceSensorKeyDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	key := &alienvault.SensorKey{ID: d.Get("key").(string)}
+	if err := m.(*alienvault.Client).DeleteSensorKeyWithContext(ctx, key); err != nil && !isNotFound(err) {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+// resourceSensorKeyCustomizeDiff checks the license has room for a new key, and plans a replacement for a key which can
+// no longer be used to activate a sensor, unless it was used to activate one. Keys which have activated a sensor have
+// done their job, so are left alone.
+func resourceSensorKeyCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+
+	if d.Id() == "" {
+		// every unused key takes a slot on the license, which the sensor activated with it goes on to use
+		return checkSensorLicense(ctx, m, 1, false)
+	}
+
+	expired := false
+	if expiresAt, err := time.Parse(time.RFC3339, d.Get("expires_at").(string)); err == nil {
+		expired = expiresAt.Before(time.Now())
+	}
+
+	activated := d.Get("node_id").(string) != ""
+	if activated || (!d.Get("consumed").(bool) && !expired) {
+		return nil
+	}
+
+	if err := d.SetNewComputed("key"); err != nil {
+		return err
+	}
+	return d.ForceNew("key")
+}
+
+// resourceSensorKeyImport imports a key by its value
+func resourceSensorKeyImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+
+	key, err := m.(*alienvault.Client).GetSensorKeyWithContext(ctx, d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	if key.Consumed && key.NodeID == nil {
+		return nil, fmt.Errorf("sensor key %s could not be found", d.Id())
+	}
+
+	d.SetId(sensorKeyID(key.ID))
+	flattenSensorKey(key, d)
+	return []*schema.ResourceData{d}, nil
+}
+
+func flattenSensorKey(key *alienvault.SensorKey, d *schema.ResourceData) {
+	d.Set("key", key.ID)
+	d.Set("created_at", time.Unix(int64(key.CreatedAt), 0).UTC().Format(time.RFC3339))
+	d.Set("expires_at", time.Unix(int64(key.ExpiresAt), 0).UTC().Format(time.RFC3339))
+	d.Set("consumed", key.Consumed || key.NodeID != nil)
+	if key.NodeID != nil {
+		d.Set("node_id", *key.NodeID)
+	} else {
+		d.Set("node_id", "")
+	}
+}
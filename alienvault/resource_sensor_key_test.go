@@ -0,0 +1,180 @@
+package alienvault
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/avtest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+const testSensorKeyConfig = `
+	resource "alienvault_sensor_key" "test" {}`
+
+// testCheckSensorKey checks the key in state is the only key held by the fake server, and stores it in key
+func testCheckSensorKey(server *avtest.Server, key *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources["alienvault_sensor_key.test"]
+		if !ok {
+			return fmt.Errorf("not found: alienvault_sensor_key.test")
+		}
+
+		keys := server.SensorKeys()
+		if len(keys) != 1 || keys[0].ID != rs.Primary.Attributes["key"] {
+			return fmt.Errorf("unexpected sensor keys %v for %s", keys, rs.Primary.Attributes["key"])
+		}
+		if rs.Primary.ID != sensorKeyID(keys[0].ID) {
+			return fmt.Errorf("unexpected ID %s", rs.Primary.ID)
+		}
+
+		*key = keys[0].ID
+		return nil
+	}
+}
+
+// testCheckSensorKeyReplaced checks the key in state is no longer the given key
+func testCheckSensorKeyReplaced(server *avtest.Server, key *string) resource.TestCheckFunc {
+	old := ""
+	return resource.ComposeAggregateTestCheckFunc(
+		func(*terraform.State) error {
+			old = *key
+			return nil
+		},
+		testCheckSensorKey(server, key),
+		func(*terraform.State) error {
+			if *key == old {
+				return fmt.Errorf("sensor key %s was not replaced", old)
+			}
+			return nil
+		},
+	)
+}
+
+func TestResourceSensorKey(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	var key string
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		CheckDestroy: func(s *terraform.State) error {
+			if keys := server.SensorKeys(); len(keys) > 0 {
+				return fmt.Errorf("%d sensor keys still exist", len(keys))
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(server, testSensorKeyConfig),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testCheckSensorKey(server, &key),
+					resource.TestCheckResourceAttr("alienvault_sensor_key.test", "consumed", "false"),
+					resource.TestCheckResourceAttr("alienvault_sensor_key.test", "node_id", ""),
+					resource.TestCheckResourceAttrSet("alienvault_sensor_key.test", "created_at"),
+					resource.TestCheckResourceAttrSet("alienvault_sensor_key.test", "expires_at"),
+				),
+			},
+			{
+				ResourceName:      "alienvault_sensor_key.test",
+				ImportState:       true,
+				ImportStateIdFunc: func(*terraform.State) (string, error) { return key, nil },
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestResourceSensorKeyReplacement(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	var key string
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(server, testSensorKeyConfig),
+				Check:  testCheckSensorKey(server, &key),
+			},
+			{
+				PreConfig: func() {
+					server.ExpireSensorKey(key)
+				},
+				Config: testProviderConfig(server, testSensorKeyConfig),
+				Check:  testCheckSensorKeyReplaced(server, &key),
+			},
+			{
+				PreConfig: func() {
+					server.RemoveSensorKey(key)
+				},
+				Config: testProviderConfig(server, testSensorKeyConfig),
+				Check:  testCheckSensorKeyReplaced(server, &key),
+			},
+		},
+	})
+}
+
+func TestResourceSensorKeyActivated(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	var key string
+	var sensor avtest.Sensor
+
+	// a key which has activated a sensor has done its job, so is kept even once it expires
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(server, testSensorKeyConfig),
+				Check:  testCheckSensorKey(server, &key),
+			},
+			{
+				PreConfig: func() {
+					sensor = server.ActivateSensorKey(key)
+					server.ExpireSensorKey(key)
+				},
+				Config: testProviderConfig(server, testSensorKeyConfig),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("alienvault_sensor_key.test", "consumed", "true"),
+					resource.TestCheckResourceAttrPtr("alienvault_sensor_key.test", "node_id", &sensor.V1ID),
+					resource.TestCheckResourceAttrPtr("alienvault_sensor_key.test", "key", &key),
+				),
+			},
+		},
+	})
+}
+
+func TestResourceSensorKeyLicenseFull(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	server.SetLicense(avtest.License{SensorNodeLimit: 1, Expiration: time.Now().Add(time.Hour).Unix()})
+
+	// the plan reserves the only slot, and applying it doesn't count the key it creates twice
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(server, testSensorKeyConfig),
+				Check:  testCheckSensorKey(server, new(string)),
+			},
+			{
+				Config: testProviderConfig(server, testSensorKeyConfig+`
+
+					resource "alienvault_sensor_key" "other" {}`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`the AlienVault license has room for 0 more sensors`),
+			},
+		},
+	})
+}
@@ -0,0 +1,62 @@
+package alienvault
+
+import (
+	"context"
+	"time"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// sensorV0Defaults are the defaults of sensor settings added since version 0 of the schema. State written before then
+// lacks them, which would otherwise show up as a change to every existing sensor.
+var sensorV0Defaults = map[string]interface{}{
+	"on_connection_lost": connectionLostError,
+	"activation_mode":    activationModeAppliance,
+	"appliance_scheme":   "http",
+	"poll_interval":      alienvault.DefaultPollPolicy.Interval.String(),
+	"max_poll_interval":  alienvault.DefaultPollPolicy.MaxInterval.String(),
+}
+
+// resourceSensorV0 is the schema of alienvault_sensor at version 0, which is only used to read old state
+func resourceSensorV0() *schema.Resource {
+
+	createTime := time.Hour
+
+	return &schema.Resource{
+		Timeouts: &schema.ResourceTimeout{
+			Create: &createTime,
+		},
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"description": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"ip": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"activation_code": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+		},
+	}
+}
+
+// upgradeSensorStateV0 fills in the defaults of any settings missing from version 0 state
+func upgradeSensorStateV0(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	if rawState == nil {
+		rawState = map[string]interface{}{}
+	}
+	for key, value := range sensorV0Defaults {
+		if _, ok := rawState[key]; !ok {
+			rawState[key] = value
+		}
+	}
+	return rawState, nil
+}
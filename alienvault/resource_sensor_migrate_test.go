@@ -0,0 +1,36 @@
+package alienvault
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeSensorStateV0(t *testing.T) {
+
+	v0 := map[string]interface{}{
+		"id":              "abc",
+		"name":            "sensor",
+		"description":     "Created by terraform",
+		"ip":              "10.0.0.1",
+		"activation_code": "",
+	}
+
+	state, err := upgradeSensorStateV0(context.Background(), v0, nil)
+	require.Nil(t, err)
+
+	assert.Equal(t, "sensor", state["name"])
+	assert.Equal(t, "10.0.0.1", state["ip"])
+	assert.Equal(t, "error", state["on_connection_lost"])
+	assert.Equal(t, "appliance", state["activation_mode"])
+	assert.Equal(t, "http", state["appliance_scheme"])
+	assert.Equal(t, "5s", state["poll_interval"])
+	assert.Equal(t, "1m0s", state["max_poll_interval"])
+
+	// anything already set is kept
+	state, err = upgradeSensorStateV0(context.Background(), map[string]interface{}{"on_connection_lost": connectionLostIgnore}, nil)
+	require.Nil(t, err)
+	assert.Equal(t, connectionLostIgnore, state["on_connection_lost"])
+}
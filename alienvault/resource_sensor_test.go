@@ -0,0 +1,615 @@
+package alienvault
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/avtest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+const testSensorConfig = `
+	resource "alienvault_sensor" "test" {
+		name        = "sensor"
+		description = "%s"
+		ip          = "10.0.0.1"
+	}`
+
+const testSensorConnectionLostConfig = `
+	resource "alienvault_sensor" "test" {
+		name               = "sensor"
+		description        = "existing"
+		ip                 = "10.0.0.1"
+		on_connection_lost = "%s"
+	}`
+
+// testCheckFakeSensor runs check against the sensor held by the fake server for the named resource
+func testCheckFakeSensor(server *avtest.Server, n string, check func(sensor avtest.Sensor) error) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("not found: %s", n)
+		}
+
+		sensor, ok := server.Sensor(rs.Primary.ID)
+		if !ok {
+			return fmt.Errorf("sensor %s does not exist", rs.Primary.ID)
+		}
+
+		return check(sensor)
+	}
+}
+
+// sensors are created by an appliance, so the existing sensor is imported and then updated and deleted
+func TestResourceSensor(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	sensor := server.AddSensor(avtest.Sensor{
+		Name:          "sensor",
+		Description:   "existing",
+		Type:          "AWS",
+		Version:       "6.0.0",
+		LastConnected: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Unix(),
+	})
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		CheckDestroy: func(s *terraform.State) error {
+			if sensors := server.Sensors(); len(sensors) > 0 {
+				return fmt.Errorf("%d sensors still exist", len(sensors))
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config:             testProviderConfig(server, fmt.Sprintf(testSensorConfig, "existing")),
+				ResourceName:       "alienvault_sensor.test",
+				ImportState:        true,
+				ImportStateId:      sensor.V1ID,
+				ImportStatePersist: true,
+				ImportStateCheck: func(states []*terraform.InstanceState) error {
+					if len(states) != 1 || states[0].ID != sensor.V1ID || states[0].Attributes["description"] != "existing" {
+						return fmt.Errorf("unexpected import: %v", states)
+					}
+					return nil
+				},
+			},
+			{
+				Config: testProviderConfig(server, fmt.Sprintf(testSensorConfig, "existing")),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testCheckFakeSensor(server, "alienvault_sensor.test", func(s avtest.Sensor) error {
+						// importing adopts the sensor
+						if s.Description != "existing [terraform:unit-test]" {
+							return fmt.Errorf("sensor was not adopted: %v", s)
+						}
+						return nil
+					}),
+					resource.TestCheckResourceAttr("alienvault_sensor.test", "status", "Ready"),
+					resource.TestCheckResourceAttr("alienvault_sensor.test", "setup_status", "Complete"),
+					resource.TestCheckResourceAttr("alienvault_sensor.test", "v1_id", sensor.V1ID),
+					resource.TestCheckResourceAttr("alienvault_sensor.test", "v2_id", sensor.V2ID),
+					resource.TestCheckResourceAttr("alienvault_sensor.test", "sensor_type", "AWS"),
+					resource.TestCheckResourceAttr("alienvault_sensor.test", "version", "6.0.0"),
+					resource.TestCheckResourceAttr("alienvault_sensor.test", "last_connected", "2020-01-02T03:04:05Z"),
+				),
+			},
+			{
+				Config: testProviderConfig(server, fmt.Sprintf(testSensorConfig, "updated")),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("alienvault_sensor.test", "description", "updated"),
+					testCheckFakeSensor(server, "alienvault_sensor.test", func(s avtest.Sensor) error {
+						if s.Description != "updated [terraform:unit-test]" {
+							return fmt.Errorf("sensor was not updated: %v", s)
+						}
+						return nil
+					}),
+				),
+			},
+		},
+	})
+}
+
+// changing a setting which only affects the provider is saved to state without touching the sensor
+func TestResourceSensorUpdateProviderSettings(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	sensor := server.AddSensor(avtest.Sensor{Name: "sensor", Description: "existing"})
+
+	var seen int
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config:             testProviderConfig(server, fmt.Sprintf(testSensorConnectionLostConfig, "error")),
+				ResourceName:       "alienvault_sensor.test",
+				ImportState:        true,
+				ImportStateId:      sensor.V1ID,
+				ImportStatePersist: true,
+			},
+			{
+				PreConfig: func() { seen = len(server.Requests()) },
+				Config:    testProviderConfig(server, fmt.Sprintf(testSensorConnectionLostConfig, "ignore")),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("alienvault_sensor.test", "on_connection_lost", "ignore"),
+					func(*terraform.State) error {
+						for _, request := range server.Requests()[seen:] {
+							if strings.HasPrefix(request, "PATCH ") {
+								return fmt.Errorf("the sensor was updated: %s", request)
+							}
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+// testCheckNoSensors checks the fake server holds no sensors
+func testCheckNoSensors(server *avtest.Server) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		if sensors := server.Sensors(); len(sensors) > 0 {
+			return fmt.Errorf("%d sensors still exist", len(sensors))
+		}
+		return nil
+	}
+}
+
+// testCheckSensorCreated checks the sensor in state was registered by the appliance and has finished its setup
+func testCheckSensorCreated(server *avtest.Server, appliance *avtest.Appliance) resource.TestCheckFunc {
+	return resource.ComposeAggregateTestCheckFunc(
+		testCheckFakeSensor(server, "alienvault_sensor.test", func(s avtest.Sensor) error {
+			if id, _ := appliance.SensorID(); s.V1ID != id {
+				return fmt.Errorf("sensor %s was not registered by the appliance", s.V1ID)
+			}
+			if s.Name != "sensor" || s.Description != "created [terraform:unit-test]" {
+				return fmt.Errorf("sensor was not named: %v", s)
+			}
+			return nil
+		}),
+		resource.TestCheckResourceAttr("alienvault_sensor.test", "status", "Ready"),
+		resource.TestCheckResourceAttr("alienvault_sensor.test", "setup_status", "Complete"),
+		resource.TestCheckResourceAttr("alienvault_sensor.test", "sensor_type", "Virtual"),
+	)
+}
+
+// the appliance is polled every few milliseconds, so creation takes no longer than the emulated appliance does
+func TestResourceSensorCreateViaAppliance(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	appliance := avtest.NewAppliance(server,
+		avtest.WithTLS(),
+		avtest.WithRegistrationDelay(time.Millisecond*50),
+		avtest.WithReadyDelay(time.Millisecond*50),
+	)
+	defer appliance.Close()
+
+	addr := appliance.Listener.Addr().(*net.TCPAddr)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		CheckDestroy:      testCheckNoSensors(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(server, fmt.Sprintf(`
+					resource "alienvault_sensor" "test" {
+						name              = "sensor"
+						description       = "created"
+						ip                = %q
+						appliance_scheme  = "https"
+						appliance_port    = %d
+						appliance_ca_cert = %q
+						poll_interval     = "5ms"
+						max_poll_interval = "20ms"
+					}`, addr.IP, addr.Port, appliance.CACertPEM())),
+				Check: testCheckSensorCreated(server, appliance),
+			},
+			{
+				// the same durations written differently are not a change
+				Config: testProviderConfig(server, fmt.Sprintf(`
+					resource "alienvault_sensor" "test" {
+						name              = "sensor"
+						description       = "created"
+						ip                = %q
+						appliance_scheme  = "https"
+						appliance_port    = %d
+						appliance_ca_cert = %q
+						poll_interval     = "5000us"
+						max_poll_interval = "0.02s"
+					}`, addr.IP, addr.Port, appliance.CACertPEM())),
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestResourceSensorCreateViaExternalActivation(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	appliance := avtest.NewAppliance(server,
+		avtest.WithRegistrationDelay(time.Millisecond*50),
+		avtest.WithReadyDelay(time.Millisecond*50),
+	)
+	defer appliance.Close()
+
+	// the appliance activates itself with the key as soon as it exists, as if it were given the key in its user data
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			if keys := server.SensorKeys(); len(keys) > 0 {
+				if err := appliance.Activate(keys[0].ID, "appliance", ""); err != nil {
+					t.Errorf("failed to activate appliance: %s", err)
+				}
+				return
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond * 5):
+			}
+		}
+	}()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		CheckDestroy:      testCheckNoSensors(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(server, `
+					resource "alienvault_sensor_key" "test" {}
+
+					resource "alienvault_sensor" "test" {
+						name              = "sensor"
+						description       = "created"
+						activation_mode   = "external"
+						activation_code   = alienvault_sensor_key.test.key
+						poll_interval     = "5ms"
+						max_poll_interval = "20ms"
+					}`),
+				Check: testCheckSensorCreated(server, appliance),
+			},
+		},
+	})
+}
+
+// testCheckSensorKept checks the sensor in state is still the given one, and it has not been deregistered
+func testCheckSensorKept(server *avtest.Server, sensor avtest.Sensor) resource.TestCheckFunc {
+	return resource.ComposeAggregateTestCheckFunc(
+		resource.TestCheckResourceAttr("alienvault_sensor.test", "id", sensor.V1ID),
+		testCheckFakeSensor(server, "alienvault_sensor.test", func(avtest.Sensor) error { return nil }),
+	)
+}
+
+// testSensorConnectionLostSteps imports a sensor, which then loses connection before the given step
+func testSensorConnectionLostSteps(server *avtest.Server, sensor avtest.Sensor, action string, step resource.TestStep) []resource.TestStep {
+	step.PreConfig = func() {
+		server.SetSensorStatus(sensor.V1ID, "Connection lost")
+	}
+	step.Config = testProviderConfig(server, fmt.Sprintf(testSensorConnectionLostConfig, action))
+	return []resource.TestStep{
+		{
+			Config:             testProviderConfig(server, fmt.Sprintf(testSensorConnectionLostConfig, action)),
+			ResourceName:       "alienvault_sensor.test",
+			ImportState:        true,
+			ImportStateId:      sensor.V1ID,
+			ImportStatePersist: true,
+		},
+		step,
+	}
+}
+
+func TestResourceSensorConnectionLost(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	sensor := server.AddSensor(avtest.Sensor{Name: "sensor", Description: "existing"})
+
+	// by default the plan fails, but the sensor is left alone
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config:             testProviderConfig(server, fmt.Sprintf(testSensorConfig, "existing")),
+				ResourceName:       "alienvault_sensor.test",
+				ImportState:        true,
+				ImportStateId:      sensor.V1ID,
+				ImportStatePersist: true,
+			},
+			{
+				PreConfig: func() {
+					server.SetSensorStatus(sensor.V1ID, "Connection lost")
+				},
+				Config:      testProviderConfig(server, fmt.Sprintf(testSensorConfig, "existing")),
+				ExpectError: regexp.MustCompile("set on_connection_lost to recreate, ignore or deregister"),
+			},
+			{
+				// changing the action in config takes effect straight away
+				Config: testProviderConfig(server, fmt.Sprintf(testSensorConnectionLostConfig, "ignore")),
+				Check:  testCheckSensorKept(server, sensor),
+			},
+		},
+	})
+}
+
+func TestResourceSensorConnectionLostIgnore(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	sensor := server.AddSensor(avtest.Sensor{Name: "sensor", Description: "existing"})
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: testSensorConnectionLostSteps(server, sensor, "ignore", resource.TestStep{
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("alienvault_sensor.test", "status", "Connection lost"),
+				testCheckSensorKept(server, sensor),
+			),
+		}),
+	})
+}
+
+func TestResourceSensorConnectionLostRecreate(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	sensor := server.AddSensor(avtest.Sensor{Name: "sensor", Description: "existing"})
+
+	// the sensor is only replaced on apply, so planning leaves it alone
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: append(testSensorConnectionLostSteps(server, sensor, "recreate", resource.TestStep{
+			PlanOnly:           true,
+			ExpectNonEmptyPlan: true,
+		}), resource.TestStep{
+			Config: testProviderConfig(server, fmt.Sprintf(testSensorConnectionLostConfig, "ignore")),
+			Check:  testCheckSensorKept(server, sensor),
+		}),
+	})
+}
+
+func TestResourceSensorConnectionLostDeregister(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	sensor := server.AddSensor(avtest.Sensor{Name: "sensor", Description: "existing"})
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: testSensorConnectionLostSteps(server, sensor, "deregister", resource.TestStep{
+			ExpectError: regexp.MustCompile("the sensor has been deregistered"),
+		}),
+	})
+
+	if _, ok := server.Sensor(sensor.V1ID); ok {
+		t.Fatal("the sensor was not deregistered")
+	}
+}
+
+func TestResourceSensorActivationValidation(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(server, `
+					resource "alienvault_sensor" "test" {
+						name = "sensor"
+					}`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`an ip is required when activation_mode is "appliance"`),
+			},
+			{
+				Config: testProviderConfig(server, `
+					resource "alienvault_sensor" "test" {
+						name            = "sensor"
+						activation_mode = "external"
+					}`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`an activation_code is required when activation_mode is "external"`),
+			},
+			{
+				Config: testProviderConfig(server, `
+					resource "alienvault_sensor" "test" {
+						name = "sensor"
+						ip   = "10.0.0.1"
+
+						bastion {
+							host = "bastion.example.com"
+							user = "terraform"
+						}
+					}`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`either a private_key or agent is required to connect to the bastion`),
+			},
+			{
+				Config: testProviderConfig(server, `
+					resource "alienvault_sensor" "test" {
+						name = "sensor"
+						ip   = "10.0.0.1"
+
+						bastion {
+							host  = "bastion.example.com"
+							user  = "terraform"
+							agent = true
+						}
+					}`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`a host_key is required to verify the bastion, unless insecure_ignore_host_key is set`),
+			},
+			{
+				// the key isn't known until it is created, which is fine
+				Config: testProviderConfig(server, `
+					resource "alienvault_sensor_key" "test" {}
+
+					resource "alienvault_sensor" "test" {
+						name            = "sensor"
+						activation_mode = "external"
+						activation_code = alienvault_sensor_key.test.key
+					}`),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+// testSensorLicense gives the fake server a license which allows the given number of sensors, and expires after the
+// given time
+func testSensorLicense(server *avtest.Server, sensors int, expiresIn time.Duration) {
+	server.SetLicense(avtest.License{SensorNodeLimit: sensors, Expiration: time.Now().Add(expiresIn).Unix()})
+}
+
+const testSensorLicenseConfig = `
+	resource "alienvault_sensor" "first" {
+		name = "first"
+		ip   = "10.0.0.1"
+	}
+
+	resource "alienvault_sensor" "second" {
+		name = "second"
+		ip   = "10.0.0.2"
+	}`
+
+func TestResourceSensorLicenseCapacity(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	server.AddSensor(avtest.Sensor{Name: "existing"})
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				PreConfig:   func() { testSensorLicense(server, 2, time.Hour) },
+				Config:      testProviderConfig(server, testSensorLicenseConfig),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`the AlienVault license has room for 1 more sensors, counting existing sensors and unused sensor keys, which is not enough for the 2 sensors and sensor keys planned`),
+			},
+			{
+				PreConfig:          func() { testSensorLicense(server, 3, time.Hour) },
+				Config:             testProviderConfig(server, testSensorLicenseConfig),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				// a sensor activated with a key takes the slot of the key
+				PreConfig: func() { testSensorLicense(server, 2, time.Hour) },
+				Config: testProviderConfig(server, `
+					resource "alienvault_sensor_key" "test" {}
+
+					resource "alienvault_sensor" "test" {
+						name            = "sensor"
+						activation_mode = "external"
+						activation_code = alienvault_sensor_key.test.key
+					}`),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				// unused keys take a slot each
+				PreConfig: func() {
+					testSensorLicense(server, 3, time.Hour)
+					server.AddSensorKey()
+				},
+				Config:      testProviderConfig(server, testSensorLicenseConfig),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`has room for 1 more sensors`),
+			},
+		},
+	})
+}
+
+func TestResourceSensorLicenseSweep(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	testSensorLicense(server, 1, time.Hour)
+	server.AddSensor(avtest.Sensor{Name: "dead", Description: "old [terraform:unit-test]", Status: "Connection lost"})
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				// the dead sensor is swept before the sensor is created, freeing its slot
+				Config:             testProviderConfig(server, fmt.Sprintf(testSensorConfig, "new")),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				// but not before a key is created
+				Config:      testProviderConfig(server, testSensorKeyConfig),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`not enough for the 1 sensors and sensor keys planned`),
+			},
+		},
+	})
+}
+
+func TestResourceSensorLicenseExpired(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	testSensorLicense(server, 5, -time.Hour)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config:      testProviderConfig(server, fmt.Sprintf(testSensorConfig, "new")),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`the AlienVault license expired at .*, so no sensors can be created`),
+			},
+			{
+				Config:      testProviderConfig(server, testSensorKeyConfig),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`the AlienVault license expired at`),
+			},
+		},
+	})
+}
+
+func TestResourceSensorLicenseCheckFails(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	// a request AlienVault rejects will be rejected again when applying, so the plan fails rather than carrying on blind
+	server.Fail("GET", "/api/1.0/license", http.StatusBadRequest, 10)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config:      testProviderConfig(server, fmt.Sprintf(testSensorConfig, "new")),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`failed to check the sensor license: unexpected status code for GET /api/1.0/license: 400`),
+			},
+		},
+	})
+}
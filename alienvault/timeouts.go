@@ -0,0 +1,8 @@
+package alienvault
+
+import (
+	"time"
+)
+
+// defaultTimeout is used for any resource operation which does not have a more specific default
+const defaultTimeout = 5 * time.Minute
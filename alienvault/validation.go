@@ -3,8 +3,10 @@ package alienvault
 import (
 	"fmt"
 	"net"
+	"net/url"
+	"time"
 
-	"github.com/form3tech-oss/alienvault"
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
 )
 
 // the following is a list of valid plugins for AV log monitoring
@@ -340,3 +342,73 @@ func validateIP(val interface{}, key string) (warns []string, errs []error) {
 	}
 	return
 }
+
+func validateConnectionLostAction(val interface{}, key string) (warns []string, errs []error) {
+	v := val.(string)
+	for _, action := range connectionLostActions {
+		if action == v {
+			return
+		}
+	}
+	errs = append(errs, fmt.Errorf("%q must be one of %q, got: %s", key, connectionLostActions, v))
+	return
+}
+
+func validateActivationMode(val interface{}, key string) (warns []string, errs []error) {
+	v := val.(string)
+	for _, mode := range activationModes {
+		if mode == v {
+			return
+		}
+	}
+	errs = append(errs, fmt.Errorf("%q must be one of %q, got: %s", key, activationModes, v))
+	return
+}
+
+func validateApplianceScheme(val interface{}, key string) (warns []string, errs []error) {
+	v := val.(string)
+	if v != "http" && v != "https" {
+		errs = append(errs, fmt.Errorf("%q must be either \"http\" or \"https\", got: %s", key, v))
+	}
+	return
+}
+
+func validatePort(val interface{}, key string) (warns []string, errs []error) {
+	v := val.(int)
+	if v < 1 || v > 65535 {
+		errs = append(errs, fmt.Errorf("%q must be between 1 and 65535, got: %d", key, v))
+	}
+	return
+}
+
+func validateURL(val interface{}, key string) (warns []string, errs []error) {
+	v := val.(string)
+	if u, err := url.Parse(v); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("%q must be a valid URL, got: %s", key, v))
+	}
+	return
+}
+
+func validateDuration(val interface{}, key string) (warns []string, errs []error) {
+	v := val.(string)
+	if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+		errs = append(errs, fmt.Errorf("%q must be a positive duration such as 30s or 5m, got: %s", key, v))
+	}
+	return
+}
+
+func validateNonNegativeInt(val interface{}, key string) (warns []string, errs []error) {
+	v := val.(int)
+	if v < 0 {
+		errs = append(errs, fmt.Errorf("%q must be 0 or more, got: %d", key, v))
+	}
+	return
+}
+
+func validateNonNegativeFloat(val interface{}, key string) (warns []string, errs []error) {
+	v := val.(float64)
+	if v < 0 {
+		errs = append(errs, fmt.Errorf("%q must be 0 or more, got: %g", key, v))
+	}
+	return
+}
@@ -1,6 +1,7 @@
 package alienvault
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -64,3 +65,167 @@ func TestIPValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestConnectionLostActionValidation(t *testing.T) {
+
+	var flagtests = []struct {
+		in    string
+		valid bool
+	}{
+		{"error", true},
+		{"recreate", true},
+		{"ignore", true},
+		{"deregister", true},
+		{"delete", false},
+		{"", false},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, errors := validateConnectionLostAction(tt.in, "on_connection_lost")
+			assert.Equal(t, tt.valid, len(errors) == 0)
+		})
+	}
+}
+
+func TestActivationModeValidation(t *testing.T) {
+
+	var flagtests = []struct {
+		in    string
+		valid bool
+	}{
+		{"appliance", true},
+		{"external", true},
+		{"user-data", false},
+		{"", false},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, errors := validateActivationMode(tt.in, "activation_mode")
+			assert.Equal(t, tt.valid, len(errors) == 0)
+		})
+	}
+}
+
+func TestApplianceSchemeValidation(t *testing.T) {
+
+	var flagtests = []struct {
+		in    string
+		valid bool
+	}{
+		{"http", true},
+		{"https", true},
+		{"ftp", false},
+		{"", false},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, errors := validateApplianceScheme(tt.in, "appliance_scheme")
+			assert.Equal(t, tt.valid, len(errors) == 0)
+		})
+	}
+}
+
+func TestPortValidation(t *testing.T) {
+
+	var flagtests = []struct {
+		in    int
+		valid bool
+	}{
+		{443, true},
+		{1, true},
+		{65535, true},
+		{0, false},
+		{65536, false},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(fmt.Sprint(tt.in), func(t *testing.T) {
+			_, errors := validatePort(tt.in, "appliance_port")
+			assert.Equal(t, tt.valid, len(errors) == 0)
+		})
+	}
+}
+
+func TestURLValidation(t *testing.T) {
+
+	var flagtests = []struct {
+		in    string
+		valid bool
+	}{
+		{"http://proxy.example.com:3128", true},
+		{"https://[2001:db8::1]:8443", true},
+		{"proxy.example.com:3128", false},
+		{"", false},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, errors := validateURL(tt.in, "appliance_proxy")
+			assert.Equal(t, tt.valid, len(errors) == 0)
+		})
+	}
+}
+
+func TestDurationValidation(t *testing.T) {
+
+	var flagtests = []struct {
+		in    string
+		valid bool
+	}{
+		{"5s", true},
+		{"250ms", true},
+		{"1m30s", true},
+		{"0s", false},
+		{"-5s", false},
+		{"5", false},
+		{"", false},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, errors := validateDuration(tt.in, "poll_interval")
+			assert.Equal(t, tt.valid, len(errors) == 0)
+		})
+	}
+}
+
+func TestNonNegativeIntValidation(t *testing.T) {
+
+	var flagtests = []struct {
+		in    int
+		valid bool
+	}{
+		{3, true},
+		{0, true},
+		{-1, false},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(fmt.Sprint(tt.in), func(t *testing.T) {
+			_, errors := validateNonNegativeInt(tt.in, "max_retries")
+			assert.Equal(t, tt.valid, len(errors) == 0)
+		})
+	}
+}
+
+func TestNonNegativeFloatValidation(t *testing.T) {
+
+	var flagtests = []struct {
+		in    float64
+		valid bool
+	}{
+		{2.5, true},
+		{0, true},
+		{-0.5, false},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(fmt.Sprint(tt.in), func(t *testing.T) {
+			_, errors := validateNonNegativeFloat(tt.in, "requests_per_second")
+			assert.Equal(t, tt.valid, len(errors) == 0)
+		})
+	}
+}
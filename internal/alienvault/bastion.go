@@ -0,0 +1,180 @@
+package alienvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// SSHBastion is a host which sensor appliances are reached through, via an SSH tunnel, when they aren't reachable
+// directly
+type SSHBastion struct {
+	Host          string // Host is the address of the bastion
+	Port          int    // Port is the port SSH listens on, or zero for 22
+	User          string // User is the user to authenticate as
+	PrivateKeyPEM []byte // PrivateKeyPEM is a PEM encoded private key to authenticate with
+	UseAgent      bool   // UseAgent authenticates with the keys held by the agent listening on SSH_AUTH_SOCK
+	HostKey       string // HostKey is the public key of the bastion in authorized_keys format
+
+	// InsecureIgnoreHostKey connects without verifying the bastion when HostKey is empty. This is unsafe, as anyone
+	// able to intercept the connection can then read what is sent through the tunnel.
+	InsecureIgnoreHostKey bool
+}
+
+// address returns the address to dial to reach the bastion
+func (bastion *SSHBastion) address() string {
+	port := bastion.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(bastion.Host, strconv.Itoa(port))
+}
+
+// clientConfig builds the SSH configuration used to connect to the bastion
+func (bastion *SSHBastion) clientConfig() (*ssh.ClientConfig, error) {
+
+	var auth []ssh.AuthMethod
+
+	if len(bastion.PrivateKeyPEM) > 0 {
+		signer, err := ssh.ParsePrivateKey(bastion.PrivateKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bastion private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if bastion.UseAgent && os.Getenv("SSH_AUTH_SOCK") == "" {
+		return nil, fmt.Errorf("an SSH agent was requested for the bastion, but SSH_AUTH_SOCK is not set")
+	}
+
+	if len(auth) == 0 && !bastion.UseAgent {
+		return nil, fmt.Errorf("either a private key or an SSH agent is required to connect to the bastion")
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	switch {
+	case bastion.HostKey != "":
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(bastion.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bastion host key: %w", err)
+		}
+		hostKeyCallback = ssh.FixedHostKey(key)
+	case bastion.InsecureIgnoreHostKey:
+		log.Printf("[WARN] no host key is set for bastion %s, so its identity will not be verified", bastion.Host)
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, fmt.Errorf("a host key is required to verify the bastion, unless verification is explicitly disabled")
+	}
+
+	return &ssh.ClientConfig{
+		User:            bastion.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         time.Second * 30,
+	}, nil
+}
+
+// sshTunnel dials connections through an SSH bastion. The connection to the bastion is made when first needed, and
+// made again if it drops, as waiting for an appliance can take a long time.
+type sshTunnel struct {
+	bastion *SSHBastion
+	config  *ssh.ClientConfig
+
+	lock   sync.Mutex
+	client *ssh.Client
+}
+
+func newSSHTunnel(bastion *SSHBastion) (*sshTunnel, error) {
+	config, err := bastion.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &sshTunnel{bastion: bastion, config: config}, nil
+}
+
+// connect returns the connection to the bastion, connecting first if necessary
+func (tunnel *sshTunnel) connect(ctx context.Context) (*ssh.Client, error) {
+	tunnel.lock.Lock()
+	defer tunnel.lock.Unlock()
+
+	if tunnel.client != nil {
+		return tunnel.client, nil
+	}
+
+	dialer := net.Dialer{Timeout: tunnel.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", tunnel.bastion.address())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to bastion: %w", err)
+	}
+
+	config := *tunnel.config
+	if tunnel.bastion.UseAgent {
+		// the agent signs for us during the handshake, so must stay connected until it is done
+		agentConn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to connect to SSH agent: %w", err)
+		}
+		defer agentConn.Close()
+		config.Auth = append(append([]ssh.AuthMethod{}, config.Auth...), ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, tunnel.bastion.address(), &config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to establish SSH connection to bastion: %w", err)
+	}
+
+	tunnel.client = ssh.NewClient(c, chans, reqs)
+	return tunnel.client, nil
+}
+
+// DialContext dials the given address from the bastion
+func (tunnel *sshTunnel) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+
+	client, err := tunnel.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.DialContext(ctx, network, address)
+	var rejected *ssh.OpenChannelError
+	if err != nil && ctx.Err() == nil && !errors.As(err, &rejected) {
+		// the bastion didn't just fail to reach the address, so the connection to it may have dropped
+		tunnel.reset(client)
+	}
+	return conn, err
+}
+
+// reset discards the given connection to the bastion, if it is still the current one
+func (tunnel *sshTunnel) reset(client *ssh.Client) {
+	tunnel.lock.Lock()
+	defer tunnel.lock.Unlock()
+
+	if tunnel.client == client {
+		tunnel.client.Close()
+		tunnel.client = nil
+	}
+}
+
+// Close closes the connection to the bastion, if any
+func (tunnel *sshTunnel) Close() error {
+	tunnel.lock.Lock()
+	defer tunnel.lock.Unlock()
+
+	if tunnel.client == nil {
+		return nil
+	}
+	err := tunnel.client.Close()
+	tunnel.client = nil
+	return err
+}
@@ -0,0 +1,198 @@
+package alienvault
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/avtest"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"gotest.tools/assert"
+)
+
+func generateSSHKey(t *testing.T) (ed25519.PrivateKey, ssh.PublicKey, []byte) {
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	sshPublic, err := ssh.NewPublicKey(public)
+	require.Nil(t, err)
+
+	block, err := ssh.MarshalPrivateKey(private, "")
+	require.Nil(t, err)
+
+	return private, sshPublic, pem.EncodeToMemory(block)
+}
+
+// newBastionEndpoint returns an endpoint for the appliance, which is reached via the bastion
+func newBastionEndpoint(appliance *avtest.Appliance, bastion *avtest.Bastion, keyPEM []byte) ApplianceEndpoint {
+	host, port := bastion.Host()
+	addr := appliance.Listener.Addr().(*net.TCPAddr)
+	return ApplianceEndpoint{
+		IP:   addr.IP,
+		Port: addr.Port,
+		Bastion: &SSHBastion{
+			Host:          host,
+			Port:          port,
+			User:          avtest.BastionUser,
+			PrivateKeyPEM: keyPEM,
+			HostKey:       bastion.HostKey(),
+		},
+	}
+}
+
+func TestCreateSensorViaBastion(t *testing.T) {
+
+	_, public, keyPEM := generateSSHKey(t)
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	appliance := avtest.NewAppliance(server)
+	defer appliance.Close()
+
+	bastion := avtest.NewBastion(public)
+	defer bastion.Close()
+
+	client := New(server.FQDN(), Credentials{Username: avtest.Username, Password: avtest.Password}, true, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	sensor := &Sensor{Name: "sensor"}
+	require.Nil(t, client.CreateSensorViaAppliance(ctx, sensor, newBastionEndpoint(appliance, bastion, keyPEM), testPollPolicy))
+
+	created, ok := server.Sensor(sensor.V1ID)
+	require.True(t, ok)
+	assert.Equal(t, "Ready", created.Status)
+
+	// both the status check and activation went through the tunnel
+	forwarded := bastion.Forwarded()
+	assert.Assert(t, len(forwarded) >= 2)
+	for _, address := range forwarded {
+		assert.Equal(t, appliance.Listener.Addr().String(), address)
+	}
+}
+
+func TestBastionWithAgent(t *testing.T) {
+
+	private, public, _ := generateSSHKey(t)
+
+	keyring := agent.NewKeyring()
+	require.Nil(t, keyring.Add(agent.AddedKey{PrivateKey: private}))
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.Nil(t, err)
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	appliance := avtest.NewAppliance(server)
+	defer appliance.Close()
+
+	bastion := avtest.NewBastion(public)
+	defer bastion.Close()
+
+	endpoint := newBastionEndpoint(appliance, bastion, nil)
+	endpoint.Bastion.UseAgent = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	client := New(server.FQDN(), Credentials{Username: avtest.Username, Password: avtest.Password}, true, 0)
+	require.Nil(t, client.waitForSensorApplianceCreation(ctx, endpoint, testPollPolicy))
+	assert.Equal(t, 1, len(bastion.Forwarded()))
+}
+
+func TestBastionRejectsWrongHostKey(t *testing.T) {
+
+	_, public, keyPEM := generateSSHKey(t)
+	_, other, _ := generateSSHKey(t)
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	appliance := avtest.NewAppliance(server)
+	defer appliance.Close()
+
+	bastion := avtest.NewBastion(public)
+	defer bastion.Close()
+
+	endpoint := newBastionEndpoint(appliance, bastion, keyPEM)
+	endpoint.Bastion.HostKey = string(ssh.MarshalAuthorizedKey(other))
+
+	client := New(server.FQDN(), Credentials{Username: avtest.Username, Password: avtest.Password}, true, 0)
+	anonymousClient, closeClient, err := client.newApplianceClient(endpoint)
+	require.Nil(t, err)
+	defer closeClient()
+
+	_, err = anonymousClient.Get(endpoint.url("/api/1.0/status"))
+	assert.ErrorContains(t, err, "host key mismatch")
+	assert.Equal(t, 0, len(bastion.Forwarded()))
+}
+
+func TestBastionRequiresCredentials(t *testing.T) {
+
+	client := New("example.alienvault.cloud", Credentials{}, false, 1)
+
+	_, _, err := client.newApplianceClient(ApplianceEndpoint{Bastion: &SSHBastion{Host: "bastion.example.com"}})
+	assert.ErrorContains(t, err, "either a private key or an SSH agent is required")
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	_, _, err = client.newApplianceClient(ApplianceEndpoint{Bastion: &SSHBastion{Host: "bastion.example.com", UseAgent: true}})
+	assert.ErrorContains(t, err, "SSH_AUTH_SOCK is not set")
+}
+
+func TestBastionRequiresHostKey(t *testing.T) {
+
+	_, public, keyPEM := generateSSHKey(t)
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	appliance := avtest.NewAppliance(server)
+	defer appliance.Close()
+
+	bastion := avtest.NewBastion(public)
+	defer bastion.Close()
+
+	endpoint := newBastionEndpoint(appliance, bastion, keyPEM)
+	endpoint.Bastion.HostKey = ""
+
+	client := New(server.FQDN(), Credentials{Username: avtest.Username, Password: avtest.Password}, true, 0)
+	_, _, err := client.newApplianceClient(endpoint)
+	assert.ErrorContains(t, err, "a host key is required to verify the bastion")
+
+	// the check can be explicitly turned off
+	endpoint.Bastion.InsecureIgnoreHostKey = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	require.Nil(t, client.waitForSensorApplianceCreation(ctx, endpoint, testPollPolicy))
+	assert.Equal(t, 1, len(bastion.Forwarded()))
+}
@@ -0,0 +1,138 @@
+package alienvault
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithListCache caches the responses of list endpoints (jobs, sensors and sensor keys) for the given duration, so that
+// reading many resources of the same type does not fetch the whole collection once per resource. Concurrent requests
+// for the same list share a single API call, and any request which modifies data clears the cache. A duration of zero
+// or less disables the cache.
+func WithListCache(ttl time.Duration) Option {
+	return func(client *Client) {
+		if ttl <= 0 {
+			client.cache = nil
+			return
+		}
+		client.cache = newListCache(ttl)
+	}
+}
+
+// listCache is a read-through cache of list response bodies, keyed by request URL
+type listCache struct {
+	ttl        time.Duration
+	lock       sync.Mutex
+	generation int
+	entries    map[string]listCacheEntry
+	calls      map[string]*listCacheCall
+}
+
+type listCacheEntry struct {
+	body   []byte
+	expiry time.Time
+}
+
+// listCacheCall is a fetch in progress, which callers asking for the same list wait on rather than starting their own
+type listCacheCall struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+func newListCache(ttl time.Duration) *listCache {
+	return &listCache{
+		ttl:     ttl,
+		entries: map[string]listCacheEntry{},
+		calls:   map[string]*listCacheCall{},
+	}
+}
+
+// get returns the cached body for key, or calls fetch to retrieve it. Only one fetch per key is in flight at a time.
+func (cache *listCache) get(ctx context.Context, key string, fetch func() ([]byte, error)) ([]byte, error) {
+
+	for {
+		cache.lock.Lock()
+
+		if entry, ok := cache.entries[key]; ok && time.Now().Before(entry.expiry) {
+			cache.lock.Unlock()
+			return entry.body, nil
+		}
+
+		if call, ok := cache.calls[key]; ok {
+			cache.lock.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-call.done:
+			}
+
+			// the caller which made the request gave up on it, but we haven't, so try again ourselves
+			if errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded) {
+				continue
+			}
+			return call.body, call.err
+		}
+
+		call := &listCacheCall{done: make(chan struct{})}
+		cache.calls[key] = call
+		generation := cache.generation
+		cache.lock.Unlock()
+
+		call.body, call.err = fetch()
+
+		cache.lock.Lock()
+		delete(cache.calls, key)
+		// don't store the result if the data was modified while we were fetching it, as it may be stale
+		if call.err == nil && generation == cache.generation {
+			cache.entries[key] = listCacheEntry{
+				body:   call.body,
+				expiry: time.Now().Add(cache.ttl),
+			}
+		}
+		cache.lock.Unlock()
+		close(call.done)
+
+		return call.body, call.err
+	}
+}
+
+// invalidate clears the cache. It is safe to call on a nil cache.
+func (cache *listCache) invalidate() {
+	if cache == nil {
+		return
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.generation++
+	cache.entries = map[string]listCacheEntry{}
+}
+
+// getList returns the body of a successful response to a list request, using the cache if it is enabled
+func (client *Client) getList(req *http.Request) ([]byte, error) {
+
+	fetch := func() ([]byte, error) {
+		resp, err := client.do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if err := checkResponse(resp); err != nil {
+			return nil, err
+		}
+
+		return ioutil.ReadAll(resp.Body)
+	}
+
+	if client.cache == nil {
+		return fetch()
+	}
+
+	return client.cache.get(req.Context(), req.URL.String(), fetch)
+}
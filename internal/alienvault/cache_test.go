@@ -0,0 +1,121 @@
+package alienvault
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+// newSchedulerTestServer returns a server which lists a single job, counting the number of list requests it receives
+func newSchedulerTestServer(t *testing.T, delay time.Duration, lists *int32) *httptest.Server {
+	return newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/1.0/scheduler") {
+			return
+		}
+		if r.Method != "GET" {
+			return
+		}
+		atomic.AddInt32(lists, 1)
+		time.Sleep(delay)
+		fmt.Fprint(w, `[{"uuid":"abc","action":"s3TrackFiles"}]`)
+	})
+}
+
+func TestListCacheServesRepeatedReads(t *testing.T) {
+
+	var lists int32
+	ts := newSchedulerTestServer(t, 0, &lists)
+	defer ts.Close()
+
+	client := newTestClient(t, ts, WithListCache(time.Minute))
+
+	for i := 0; i < 5; i++ {
+		job, err := client.GetAWSBucketJob("abc")
+		require.Nil(t, err)
+		assert.Equal(t, "abc", job.UUID)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lists))
+}
+
+func TestListCacheExpires(t *testing.T) {
+
+	var lists int32
+	ts := newSchedulerTestServer(t, 0, &lists)
+	defer ts.Close()
+
+	client := newTestClient(t, ts, WithListCache(time.Millisecond*50))
+
+	_, err := client.GetAWSBucketJobs()
+	require.Nil(t, err)
+	time.Sleep(time.Millisecond * 100)
+	_, err = client.GetAWSBucketJobs()
+	require.Nil(t, err)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&lists))
+}
+
+func TestListCacheInvalidatedByMutation(t *testing.T) {
+
+	var lists int32
+	ts := newSchedulerTestServer(t, 0, &lists)
+	defer ts.Close()
+
+	client := newTestClient(t, ts, WithListCache(time.Minute))
+
+	_, err := client.GetAWSBucketJobs()
+	require.Nil(t, err)
+
+	require.Nil(t, client.DeleteAWSBucketJob(&AWSBucketJob{job: job{UUID: "abc"}}))
+
+	_, err = client.GetAWSBucketJobs()
+	require.Nil(t, err)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&lists))
+}
+
+func TestListCacheCoalescesConcurrentMisses(t *testing.T) {
+
+	var lists int32
+	ts := newSchedulerTestServer(t, time.Millisecond*100, &lists)
+	defer ts.Close()
+
+	client := newTestClient(t, ts, WithListCache(time.Minute))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetAWSBucketJobs()
+			require.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lists))
+}
+
+func TestListCacheDisabledByDefault(t *testing.T) {
+
+	var lists int32
+	ts := newSchedulerTestServer(t, 0, &lists)
+	defer ts.Close()
+
+	client := newTestClient(t, ts, WithListCache(0))
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetAWSBucketJobs()
+		require.Nil(t, err)
+	}
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&lists))
+}
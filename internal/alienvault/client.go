@@ -0,0 +1,383 @@
+package alienvault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"sync"
+	"time"
+)
+
+// Client is an API client for interacting with AlienVault USM Anywhere
+type Client struct {
+	creds               Credentials
+	fqdn                string
+	httpClient          *http.Client
+	skipTLSVerification bool
+	version             int
+
+	authLock           sync.Mutex
+	authenticated      bool
+	authErr            error
+	sensorAPIVersion   int
+	v1SensorsAvailable bool
+
+	tokenLock sync.Mutex
+	token     *oauthToken
+
+	sessionLock       sync.Mutex
+	sessionGeneration int
+
+	retryPolicy RetryPolicy
+	limiter     *rateLimiter
+	inFlight    chan struct{}
+	tlsOptions  TLSOptions
+	proxyURL    string
+	cache       *listCache
+	readOnly    bool
+
+	ownershipTag string
+
+	credentialsFunc func(ctx context.Context) (Credentials, error)
+
+	reservations sensorReservations
+}
+
+// Option configures optional behaviour of the client
+type Option func(*Client)
+
+// WithCredentialsFunc makes the client fetch its credentials from the given func when it first authenticates, instead
+// of using those passed to New. Credentials which take an external command or a secret store to fetch are then only
+// fetched if the client is used.
+func WithCredentialsFunc(f func(ctx context.Context) (Credentials, error)) Option {
+	return func(client *Client) {
+		client.credentialsFunc = f
+	}
+}
+
+// WithReadOnly prevents the client from making any changes. Every method which would modify data returns a
+// *ReadOnlyError before sending any request.
+func WithReadOnly(readOnly bool) Option {
+	return func(client *Client) {
+		client.readOnly = readOnly
+	}
+}
+
+// checkWritable returns a *ReadOnlyError if the client is in read-only mode
+func (client *Client) checkWritable(resource string, operation string) error {
+	if client.readOnly {
+		return &ReadOnlyError{Resource: resource, Operation: operation}
+	}
+	return nil
+}
+
+// Credentials contain a username and password for accessing the AV USM system, and/or a client ID and secret for
+// accessing the public v2 API using the OAuth2 client credentials flow
+type Credentials struct {
+	Username     string `json:"email"`
+	Password     string `json:"password"`
+	ClientID     string `json:"-"`
+	ClientSecret string `json:"-"`
+}
+
+// hasSession returns true if the credentials can be used to create a cookie session for the internal API
+func (creds Credentials) hasSession() bool {
+	return creds.Username != "" && creds.Password != ""
+}
+
+// hasClientCredentials returns true if the credentials can be used to obtain a bearer token for the public v2 API
+func (creds Credentials) hasClientCredentials() bool {
+	return creds.ClientID != "" && creds.ClientSecret != ""
+}
+
+// validate ensures at least one complete set of credentials has been provided
+func (creds Credentials) validate() error {
+	if (creds.Username == "") != (creds.Password == "") {
+		return fmt.Errorf("a username and password must be provided together")
+	}
+	if (creds.ClientID == "") != (creds.ClientSecret == "") {
+		return fmt.Errorf("a client ID and secret must be provided together")
+	}
+	if !creds.hasSession() && !creds.hasClientCredentials() {
+		return fmt.Errorf("either a username and password or a client ID and secret must be provided")
+	}
+	return nil
+}
+
+// New creates a new client using the provided FQDN and credentials. No requests are made until the client is first
+// used, at which point it authenticates automatically and detects which API version to use for each endpoint. A
+// non-zero version forces that version to be used for sensor endpoints instead.
+func New(fqdn string, creds Credentials, skipTLSVerification bool, version int, options ...Option) *Client {
+	client := &Client{
+		version:             version,
+		fqdn:                fqdn,
+		creds:               creds,
+		skipTLSVerification: skipTLSVerification,
+		retryPolicy:         DefaultRetryPolicy,
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
+
+// createRequest creates a request for an endpoint which is only available in the internal v1 API
+func (client *Client) createRequest(ctx context.Context, method string, path string, body io.Reader) (*http.Request, error) {
+
+	if err := client.ensureAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	if !client.creds.hasSession() {
+		return nil, fmt.Errorf("%s %s is only available via the internal API, which requires a username and password", method, path)
+	}
+
+	return client.createSessionRequest(ctx, internalAPIVersion, method, path, body)
+}
+
+// createSessionRequest creates a request for the given version of the API using the current cookie session
+func (client *Client) createSessionRequest(ctx context.Context, version int, method string, path string, body io.Reader) (*http.Request, error) {
+
+	// The 1.0 API requires the specific content type below and an X-XSRF-TOKEN header set to the value of the XSRF-TOKEN cookie
+
+	req, err := http.NewRequestWithContext(ctx, method, client.apiURL(version, path), body)
+	if err != nil {
+		return nil, err
+	}
+	cookies := client.httpClient.Jar.Cookies(req.URL)
+	for i := range cookies {
+		cookie := cookies[i]
+		if cookie.Name == "XSRF-TOKEN" {
+			req.Header.Set("X-XSRF-TOKEN", cookie.Value)
+		}
+	}
+	req.Header.Set("Origin", fmt.Sprintf("https://%s", client.fqdn))
+	req.Header.Set("Referer", fmt.Sprintf("https://%s/", client.fqdn))
+	req.Header.Set("Content-Type", "application/json;charset=UTF-8")
+	return req, nil
+}
+
+// createPublicRequest creates a request for an endpoint which is also available in the public v2 API. If client
+// credentials have been provided the request is sent to the v2 API with a bearer token, otherwise the cookie session is
+// used with whichever API version the control node supports.
+func (client *Client) createPublicRequest(ctx context.Context, method string, path string, body io.Reader) (*http.Request, error) {
+
+	if err := client.ensureAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	if !client.creds.hasClientCredentials() {
+		return client.createSessionRequest(ctx, client.sensorAPIVersion, method, path, body)
+	}
+
+	token, err := client.bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, client.apiURL(2, path), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Content-Type", "application/json;charset=UTF-8")
+	return req, nil
+}
+
+// publicAPIVersion returns the version of the API used for requests created by createPublicRequest. It is only valid
+// once the client has authenticated.
+func (client *Client) publicAPIVersion() int {
+	if client.creds.hasClientCredentials() {
+		return 2
+	}
+	return client.sensorAPIVersion
+}
+
+// apiURL returns the URL of the given path in the given version of the API
+func (client *Client) apiURL(version int, path string) string {
+	return fmt.Sprintf("https://%s/api/%d.0%s", client.fqdn, version, path)
+}
+
+// Authenticate gives the client a session to use in subsequent calls. Calling this is optional, as the client
+// authenticates on first use, but it allows bad credentials to be detected up front.
+func (client *Client) Authenticate() error {
+	return client.AuthenticateWithContext(context.Background())
+}
+
+// AuthenticateWithContext gives the client a session to use in subsequent calls. Calling this is optional, as the
+// client authenticates on first use, but it allows bad credentials to be detected up front. It always makes a new
+// attempt, even if an earlier one failed.
+func (client *Client) AuthenticateWithContext(ctx context.Context) error {
+
+	client.authLock.Lock()
+	defer client.authLock.Unlock()
+
+	client.authErr = nil
+	if err := client.authenticate(ctx); err != nil {
+		client.rememberAuthError(ctx, err)
+		return err
+	}
+
+	client.authenticated = true
+	return nil
+}
+
+// ensureAuthenticated authenticates the client if this has not already been done. A failed attempt is remembered,
+// so that requests waiting on it, and any made later, fail with the same error rather than each trying to log in with
+// credentials which have already been rejected.
+func (client *Client) ensureAuthenticated(ctx context.Context) error {
+
+	client.authLock.Lock()
+	defer client.authLock.Unlock()
+
+	if client.authenticated {
+		return nil
+	}
+
+	if client.authErr == nil {
+		if err := client.authenticate(ctx); err != nil {
+			client.rememberAuthError(ctx, err)
+			return fmt.Errorf("failed to authenticate with AlienVault: %w", err)
+		}
+		client.authenticated = true
+		return nil
+	}
+
+	return fmt.Errorf("failed to authenticate with AlienVault: %w", client.authErr)
+}
+
+// rememberAuthError keeps the error from a failed attempt to authenticate, unless it was only caused by the context
+// of that attempt ending, which says nothing about whether the next attempt will succeed.
+func (client *Client) rememberAuthError(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	client.authErr = err
+}
+
+func (client *Client) authenticate(ctx context.Context) error {
+
+	if client.fqdn == "" {
+		return fmt.Errorf("the FQDN of an AlienVault instance must be provided")
+	}
+
+	if client.credentialsFunc != nil {
+		creds, err := client.credentialsFunc(ctx)
+		if err != nil {
+			return err
+		}
+		// the credentials are only fetched once, as they are kept for renewing the session
+		client.creds = creds
+		client.credentialsFunc = nil
+	}
+
+	if err := client.creds.validate(); err != nil {
+		return err
+	}
+
+	transport, err := client.newTransport()
+	if err != nil {
+		return err
+	}
+
+	cookieJar, _ := cookiejar.New(nil)
+	client.httpClient = &http.Client{
+		Transport: transport,
+		Jar:       cookieJar,
+	}
+
+	// fetch a bearer token up front so bad client credentials are reported straight away
+	if client.creds.hasClientCredentials() {
+		if _, err := client.bearerToken(ctx); err != nil {
+			return err
+		}
+	}
+
+	if client.creds.hasSession() {
+		if err := client.login(ctx); err != nil {
+			return err
+		}
+	}
+
+	return client.negotiateSensorAPIVersion(ctx)
+}
+
+// login creates a new cookie session for the internal API using the username and password
+func (client *Client) login(ctx context.Context) error {
+
+	// Unfortunately job schedules and other things we need are not supported in the public v2 REST API,
+	// so we have to use their internal one. The auth on this uses cookies, so we have to set this up here.
+
+	credsData, err := json.Marshal(client.creds)
+	if err != nil {
+		return err
+	}
+
+	// grab XSRF token etc.
+	{
+		req, err := http.NewRequestWithContext(ctx, "GET", client.apiURL(2, "/users/me"), nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.retry(req, client.send)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+
+	// do login
+	{
+		req, err := client.createSessionRequest(ctx, internalAPIVersion, "POST", "/login", bytes.NewBuffer(credsData))
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.retry(req, client.send)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != 200 {
+			d, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			return &AuthError{StatusCode: resp.StatusCode, Body: string(d)}
+		}
+		resp.Body.Close()
+	}
+
+	// get new csrf post-login
+	{
+		req, err := client.createSessionRequest(ctx, internalAPIVersion, "GET", "/", nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.retry(req, client.send)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+
+	return nil
+}
+
+// sleep pauses for the given duration, returning early with the context's error if it is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,181 @@
+package alienvault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+const TestAPIVersion = 2
+
+// testCredentials are the credentials used by test clients, which servers made by newTestServer accept
+var testCredentials = Credentials{Username: "a", Password: "b"}
+
+// newTestServer returns a server which accepts any login, and passes every other request to the given handler
+func newTestServer(handler http.HandlerFunc) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/2.0/users/me", "/api/1.0/login", "/api/1.0/":
+			return
+		}
+		handler(w, r)
+	}))
+}
+
+// newTestClient returns a client for the given server which has already logged in with testCredentials
+func newTestClient(t *testing.T, ts *httptest.Server, options ...Option) *Client {
+	client := New(testFQDN(ts), testCredentials, true, 1, options...)
+	require.Nil(t, client.Authenticate())
+	return client
+}
+
+// testFQDN returns the address of the given server in the form New expects
+func testFQDN(ts *httptest.Server) string {
+	return strings.TrimPrefix(ts.URL, "https://")
+}
+
+// We just test that authentication theoretically works here, whereas all resource tests will do a proper e2e auth
+func TestClientAuth(t *testing.T) {
+
+	actualToken := ""
+	var postedData []byte
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualToken = r.Header.Get("X-XSRF-TOKEN")
+		w.Header().Add("Set-Cookie", "XSRF-TOKEN=abc123")
+		w.Header().Add("Set-Cookie", "SESSION=mysession")
+
+		if strings.HasSuffix(r.RequestURI, "/login") {
+			var err error
+			postedData, err = ioutil.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}
+	}))
+	defer ts.Close()
+
+	creds := Credentials{
+		Username: "something",
+		Password: "something",
+	}
+
+	client := New(testFQDN(ts), creds, true, TestAPIVersion)
+
+	err := client.Authenticate()
+	require.Nil(t, err)
+
+	expectedCreds, err := json.Marshal(creds)
+	require.Nil(t, err)
+
+	assert.Equal(t, string(expectedCreds), string(postedData))
+
+	assert.Equal(t, "abc123", actualToken)
+
+}
+
+func TestClientAuthRequiresCredentials(t *testing.T) {
+	client := New("127.0.0.1:1", Credentials{}, true, TestAPIVersion)
+	require.NotNil(t, client.Authenticate())
+}
+
+func TestClientRequestsHonourContext(t *testing.T) {
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/1.0/license" {
+			// hang until the client gives up
+			<-r.Context().Done()
+		}
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t, ts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.GetLicenseWithContext(ctx)
+	require.NotNil(t, err)
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Assert(t, time.Since(start) < time.Second*5)
+}
+
+func TestClientAuthenticatesOnFirstUse(t *testing.T) {
+
+	var logins int32
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/1.0/login":
+			atomic.AddInt32(&logins, 1)
+		case "/api/1.0/license":
+			fmt.Fprint(w, `{"sensorNodesAllowed":2}`)
+		}
+	}))
+	defer ts.Close()
+
+	client := New(testFQDN(ts), testCredentials, true, 1)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&logins))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetLicense()
+			require.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&logins))
+}
+
+func TestClientRemembersAuthenticationFailure(t *testing.T) {
+
+	var logins int32
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/1.0/login" {
+			atomic.AddInt32(&logins, 1)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	client := New(testFQDN(ts), Credentials{Username: "a", Password: "wrong"}, true, 1)
+
+	// requests waiting on the failed login are given its error, rather than each logging in again
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetLicense()
+			var authErr *AuthError
+			assert.Assert(t, errors.As(err, &authErr))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&logins))
+
+	// authenticating explicitly always makes a new attempt
+	err := client.Authenticate()
+	var authErr *AuthError
+	assert.Assert(t, errors.As(err, &authErr))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&logins))
+}
@@ -0,0 +1,73 @@
+package alienvault
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// NotFoundError is returned when the requested object does not exist in AlienVault
+type NotFoundError struct {
+	Resource string // Resource is the type of object which was requested, e.g. "sensor"
+	ID       string // ID is the identifier of the object which was requested
+}
+
+func (err *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s could not be found", err.Resource, err.ID)
+}
+
+// AuthError is returned when AlienVault rejects the credentials in use, even after attempting to renew the session
+type AuthError struct {
+	StatusCode int    // StatusCode is the HTTP status code returned by the API
+	Body       string // Body is the body of the response returned by the API
+}
+
+func (err *AuthError) Error() string {
+	return fmt.Sprintf("authentication failed with status code %d: %s", err.StatusCode, err.Body)
+}
+
+// APIError is returned when the API responds with an unexpected status code
+type APIError struct {
+	Method     string // Method is the HTTP method of the failed request
+	Path       string // Path is the URL path of the failed request
+	StatusCode int    // StatusCode is the HTTP status code returned by the API
+	Body       string // Body is the body of the response returned by the API
+}
+
+func (err *APIError) Error() string {
+	return fmt.Sprintf("unexpected status code for %s %s: %d: %s", err.Method, err.Path, err.StatusCode, err.Body)
+}
+
+// ReadOnlyError is returned when a client in read-only mode is asked to make a change. No request is sent to the API.
+type ReadOnlyError struct {
+	Resource  string // Resource is the type of object which would have been changed, e.g. "sensor"
+	Operation string // Operation is the change which was refused, e.g. "delete"
+}
+
+func (err *ReadOnlyError) Error() string {
+	return fmt.Sprintf("refusing to %s %s: the client is in read-only mode", err.Operation, err.Resource)
+}
+
+// checkResponse returns an *AuthError or *APIError if the response does not have a 2xx status code
+func checkResponse(resp *http.Response) error {
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return &AuthError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+		}
+	}
+
+	return &APIError{
+		Method:     resp.Request.Method,
+		Path:       resp.Request.URL.Path,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+	}
+}
@@ -0,0 +1,95 @@
+package alienvault
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+// newErrorTestClient returns a client which makes no retries, for a server using the given handler
+func newErrorTestClient(t *testing.T, handler http.HandlerFunc) (*Client, func()) {
+	ts := newTestServer(handler)
+	return newTestClient(t, ts, WithRetryPolicy(RetryPolicy{})), ts.Close
+}
+
+func TestNotFoundError(t *testing.T) {
+
+	client, done := newErrorTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	defer done()
+
+	_, err := client.GetAWSBucketJob("abc")
+
+	var notFound *NotFoundError
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "job", notFound.Resource)
+	assert.Equal(t, "abc", notFound.ID)
+
+	_, err = client.GetSensor("def")
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "sensor", notFound.Resource)
+}
+
+func TestAPIError(t *testing.T) {
+
+	client, done := newErrorTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `something broke`)
+	})
+	defer done()
+
+	_, err := client.GetAWSCloudWatchJob("abc")
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+	assert.Equal(t, "something broke", apiErr.Body)
+	assert.Equal(t, "GET", apiErr.Method)
+	assert.Equal(t, "/api/1.0/scheduler", apiErr.Path)
+
+	var notFound *NotFoundError
+	assert.Assert(t, !errors.As(err, &notFound))
+}
+
+func TestAuthError(t *testing.T) {
+
+	client, done := newErrorTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	defer done()
+
+	err := client.DeleteSensor(&Sensor{V1ID: "abc"})
+
+	var authErr *AuthError
+	require.True(t, errors.As(err, &authErr))
+	assert.Equal(t, http.StatusForbidden, authErr.StatusCode)
+}
+
+func TestDeleteJobNotFoundError(t *testing.T) {
+
+	client, done := newErrorTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	defer done()
+
+	var notFound *NotFoundError
+
+	bucketJob := &AWSBucketJob{}
+	bucketJob.UUID = "abc"
+	err := client.DeleteAWSBucketJob(bucketJob)
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "job", notFound.Resource)
+	assert.Equal(t, "abc", notFound.ID)
+
+	cloudWatchJob := &AWSCloudWatchJob{}
+	cloudWatchJob.UUID = "def"
+	err = client.DeleteAWSCloudWatchJob(cloudWatchJob)
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "job", notFound.Resource)
+	assert.Equal(t, "def", notFound.ID)
+}
@@ -58,6 +58,8 @@ type job struct {
 	Action      JobAction      `json:"action"`         // Action describes the action associated with this job e.g. "s3TrackFiles". You do not usually need to populate this, it will be filled by default.
 	Type        JobType        `json:"type"`           // Type describes the type of job e.g. "collection" for log collection jobs. You do not usually need to populate this, it will be filled by default.
 	Custom      bool           `json:"custom"`         // Custom describes whether the job was built in or a custom job created by the user. Read-only.
+
+	OwnershipTag string `json:"-"` // OwnershipTag is the ownership tag of the client which created or last updated the job, if any. Read-only.
 }
 
 type jobParams struct {
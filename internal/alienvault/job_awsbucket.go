@@ -0,0 +1,232 @@
+package alienvault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// AWSBucketJob is a scheduled job for retrieving logs from an S3 bucket
+type AWSBucketJob struct {
+	job
+	Params AWSBucketJobParams `json:"params"` // Params allows you to dictate which bucket and path to use for the job, and specify which plugin should be used to process the logs.
+}
+
+// AWSBucketJobParams are parameters for an AWSBucketJob
+type AWSBucketJobParams struct {
+	jobParams
+	BucketName string `json:"bucketName"` // The name of the bucket to use when retrieving logs for this job
+	Path       string `json:"path"`       // The path to use when looking for logs in the specified bucket
+}
+
+func (job *AWSBucketJob) enforceTypeValues() {
+	job.Custom = true
+	job.App = JobApplicationAWS
+	job.Action = JobActionMonitorBucket
+	job.Type = JobTypeCollection
+}
+
+// GetAWSBucketJobs returns a slice of all AWS Bucket jobs
+func (client *Client) GetAWSBucketJobs() ([]AWSBucketJob, error) {
+	return client.GetAWSBucketJobsWithContext(context.Background())
+}
+
+// GetAWSBucketJobsWithContext returns a slice of all AWS Bucket jobs
+func (client *Client) GetAWSBucketJobsWithContext(ctx context.Context) ([]AWSBucketJob, error) {
+
+	req, err := client.createRequest(ctx, "GET", "/scheduler", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := client.getList(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []AWSBucketJob
+
+	if err := json.Unmarshal(body, &jobs); err != nil {
+		return nil, err
+	}
+
+	var outputJobs []AWSBucketJob
+
+	for _, job := range jobs {
+		if job.Action == JobActionMonitorBucket {
+			job.Description, job.OwnershipTag = splitOwnershipMarker(job.Description)
+			outputJobs = append(outputJobs, job)
+		}
+	}
+
+	return outputJobs, nil
+}
+
+// GetAWSBucketJob returns a particular *AWSBucketJob as identified by the UUID parameter
+func (client *Client) GetAWSBucketJob(uuid string) (*AWSBucketJob, error) {
+	return client.GetAWSBucketJobWithContext(context.Background(), uuid)
+}
+
+// GetAWSBucketJobWithContext returns a particular *AWSBucketJob as identified by the UUID parameter
+func (client *Client) GetAWSBucketJobWithContext(ctx context.Context, uuid string) (*AWSBucketJob, error) {
+
+	// there is no individual GET endpoint for this, so we have to return all jobs and filter
+
+	jobs, err := client.GetAWSBucketJobsWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		if job.UUID == uuid {
+			return &job, nil
+		}
+	}
+
+	return nil, &NotFoundError{Resource: "job", ID: uuid}
+}
+
+// CreateAWSBucketJob creates a new bucket job
+func (client *Client) CreateAWSBucketJob(j *AWSBucketJob) error {
+	return client.CreateAWSBucketJobWithContext(context.Background(), j)
+}
+
+// CreateAWSBucketJobWithContext creates a new bucket job
+func (client *Client) CreateAWSBucketJobWithContext(ctx context.Context, j *AWSBucketJob) error {
+
+	if err := client.checkWritable("AWS bucket job", "create"); err != nil {
+		return err
+	}
+
+	if j.UUID != "" {
+		return fmt.Errorf("you cannot specify a UUID when creating a job")
+	}
+
+	// force values for this subtype of job
+	j.enforceTypeValues()
+
+	description := j.Description
+	j.Description = client.markOwned(description, "")
+	data, err := json.Marshal(j)
+	j.Description = description
+	if err != nil {
+		return err
+	}
+
+	req, err := client.createRequest(ctx, "POST", "/scheduler", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	createdJob := AWSBucketJob{}
+	if err := json.NewDecoder(resp.Body).Decode(&createdJob); err != nil {
+		return err
+	}
+
+	if createdJob.UUID == "" {
+		return fmt.Errorf("failed to create the job")
+	}
+
+	j.UUID = createdJob.UUID
+	return nil
+}
+
+// UpdateAWSBucketJob updates an AWS bucket job
+func (client *Client) UpdateAWSBucketJob(j *AWSBucketJob) error {
+	return client.UpdateAWSBucketJobWithContext(context.Background(), j)
+}
+
+// UpdateAWSBucketJobWithContext updates an AWS bucket job
+func (client *Client) UpdateAWSBucketJobWithContext(ctx context.Context, j *AWSBucketJob) error {
+
+	if err := client.checkWritable("AWS bucket job", "update"); err != nil {
+		return err
+	}
+
+	// force values for this subtype of job
+	j.enforceTypeValues()
+
+	owner, err := client.currentOwner(j.OwnershipTag, func() (string, error) {
+		current, err := client.GetAWSBucketJobWithContext(ctx, j.UUID)
+		if err != nil {
+			return "", err
+		}
+		return current.OwnershipTag, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	description := j.Description
+	j.Description = client.markOwned(description, owner)
+	data, err := json.Marshal(j)
+	j.Description = description
+	if err != nil {
+		return err
+	}
+
+	req, err := client.createRequest(ctx, "PUT", fmt.Sprintf("/scheduler/%s", j.UUID), bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	createdJob := job{}
+	if err := json.NewDecoder(resp.Body).Decode(&createdJob); err != nil {
+		return err
+	}
+
+	j.UUID = createdJob.UUID
+	return nil
+}
+
+// DeleteAWSBucketJob deletes a bucket job
+func (client *Client) DeleteAWSBucketJob(j *AWSBucketJob) error {
+	return client.DeleteAWSBucketJobWithContext(context.Background(), j)
+}
+
+// DeleteAWSBucketJobWithContext deletes a bucket job. A *NotFoundError is returned if the job no longer exists.
+func (client *Client) DeleteAWSBucketJobWithContext(ctx context.Context, j *AWSBucketJob) error {
+
+	if err := client.checkWritable("AWS bucket job", "delete"); err != nil {
+		return err
+	}
+
+	req, err := client.createRequest(ctx, "DELETE", fmt.Sprintf("/scheduler/%s", j.UUID), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &NotFoundError{Resource: "job", ID: j.UUID}
+	}
+
+	return checkResponse(resp)
+}
@@ -0,0 +1,234 @@
+package alienvault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// AWSCloudWatchJob is a job which retrieves logs from cloudwatch groups(s)/stream(s)
+type AWSCloudWatchJob struct {
+	job
+	Params AWSCloudWatchJobParams `json:"params"` // Params allows you to specify which region/group/stream you wish to retrieve logs from, and which plugin should be used to process those logs
+}
+
+// AWSCloudWatchJobParams allows you to specify cloudwatch job parameters
+type AWSCloudWatchJobParams struct {
+	jobParams
+	Region string `json:"regionName"` // The region to use when retrieving logs from cloudwatch
+	Group  string `json:"groupName"`  // The group to use when retrieving logs from cloudwatch
+	Stream string `json:"streamName"` // The stream to use when retrieving logs from cloudwatch
+}
+
+func (job *AWSCloudWatchJob) enforceTypeValues() {
+	job.Custom = true
+	job.App = JobApplicationAWS
+	job.Action = JobActionMonitorCloudWatch
+	job.Type = JobTypeCollection
+}
+
+// GetAWSCloudWatchJobs returns all AWS CloudWatch jobs
+func (client *Client) GetAWSCloudWatchJobs() ([]AWSCloudWatchJob, error) {
+	return client.GetAWSCloudWatchJobsWithContext(context.Background())
+}
+
+// GetAWSCloudWatchJobsWithContext returns all AWS CloudWatch jobs
+func (client *Client) GetAWSCloudWatchJobsWithContext(ctx context.Context) ([]AWSCloudWatchJob, error) {
+
+	req, err := client.createRequest(ctx, "GET", "/scheduler", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := client.getList(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []AWSCloudWatchJob
+
+	if err := json.Unmarshal(body, &jobs); err != nil {
+		return nil, err
+	}
+
+	var outputJobs []AWSCloudWatchJob
+
+	for _, job := range jobs {
+		if job.Action == JobActionMonitorCloudWatch {
+			job.Description, job.OwnershipTag = splitOwnershipMarker(job.Description)
+			outputJobs = append(outputJobs, job)
+		}
+	}
+
+	return outputJobs, nil
+}
+
+// GetAWSCloudWatchJob returns a particular *AWSCloudWatchJob as identified by the UUID parameter
+func (client *Client) GetAWSCloudWatchJob(uuid string) (*AWSCloudWatchJob, error) {
+	return client.GetAWSCloudWatchJobWithContext(context.Background(), uuid)
+}
+
+// GetAWSCloudWatchJobWithContext returns a particular *AWSCloudWatchJob as identified by the UUID parameter
+func (client *Client) GetAWSCloudWatchJobWithContext(ctx context.Context, uuid string) (*AWSCloudWatchJob, error) {
+
+	// there is no individual GET endpoint for this, so we have to return all jobs and filter
+
+	jobs, err := client.GetAWSCloudWatchJobsWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		if job.UUID == uuid {
+			return &job, nil
+		}
+	}
+
+	return nil, &NotFoundError{Resource: "job", ID: uuid}
+}
+
+// CreateAWSCloudWatchJob creates a new AWS cloudwatch job
+func (client *Client) CreateAWSCloudWatchJob(j *AWSCloudWatchJob) error {
+	return client.CreateAWSCloudWatchJobWithContext(context.Background(), j)
+}
+
+// CreateAWSCloudWatchJobWithContext creates a new AWS cloudwatch job
+func (client *Client) CreateAWSCloudWatchJobWithContext(ctx context.Context, j *AWSCloudWatchJob) error {
+
+	if err := client.checkWritable("AWS CloudWatch job", "create"); err != nil {
+		return err
+	}
+
+	if j.UUID != "" {
+		return fmt.Errorf("you cannot specify a UUID when creating a job")
+	}
+
+	// force values for this subtype of job
+	j.enforceTypeValues()
+
+	description := j.Description
+	j.Description = client.markOwned(description, "")
+	data, err := json.Marshal(j)
+	j.Description = description
+	if err != nil {
+		return err
+	}
+
+	req, err := client.createRequest(ctx, "POST", "/scheduler", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	createdJob := AWSCloudWatchJob{}
+	if err := json.NewDecoder(resp.Body).Decode(&createdJob); err != nil {
+		return err
+	}
+
+	if createdJob.UUID == "" {
+		return fmt.Errorf("failed to create the job")
+	}
+
+	j.UUID = createdJob.UUID
+	return nil
+}
+
+// UpdateAWSCloudWatchJob updates an existing AWS cloudwatch job
+func (client *Client) UpdateAWSCloudWatchJob(j *AWSCloudWatchJob) error {
+	return client.UpdateAWSCloudWatchJobWithContext(context.Background(), j)
+}
+
+// UpdateAWSCloudWatchJobWithContext updates an existing AWS cloudwatch job
+func (client *Client) UpdateAWSCloudWatchJobWithContext(ctx context.Context, j *AWSCloudWatchJob) error {
+
+	if err := client.checkWritable("AWS CloudWatch job", "update"); err != nil {
+		return err
+	}
+
+	// force values for this subtype of job
+	j.enforceTypeValues()
+
+	owner, err := client.currentOwner(j.OwnershipTag, func() (string, error) {
+		current, err := client.GetAWSCloudWatchJobWithContext(ctx, j.UUID)
+		if err != nil {
+			return "", err
+		}
+		return current.OwnershipTag, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	description := j.Description
+	j.Description = client.markOwned(description, owner)
+	data, err := json.Marshal(j)
+	j.Description = description
+	if err != nil {
+		return err
+	}
+
+	req, err := client.createRequest(ctx, "PUT", fmt.Sprintf("/scheduler/%s", j.UUID), bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	createdJob := job{}
+	if err := json.NewDecoder(resp.Body).Decode(&createdJob); err != nil {
+		return err
+	}
+
+	j.UUID = createdJob.UUID
+	return nil
+}
+
+// DeleteAWSCloudWatchJob deletes an existing AWS cloudwatch job
+func (client *Client) DeleteAWSCloudWatchJob(j *AWSCloudWatchJob) error {
+	return client.DeleteAWSCloudWatchJobWithContext(context.Background(), j)
+}
+
+// DeleteAWSCloudWatchJobWithContext deletes an existing AWS cloudwatch job. A *NotFoundError is returned if the job no
+// longer exists.
+func (client *Client) DeleteAWSCloudWatchJobWithContext(ctx context.Context, j *AWSCloudWatchJob) error {
+
+	if err := client.checkWritable("AWS CloudWatch job", "delete"); err != nil {
+		return err
+	}
+
+	req, err := client.createRequest(ctx, "DELETE", fmt.Sprintf("/scheduler/%s", j.UUID), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &NotFoundError{Resource: "job", ID: j.UUID}
+	}
+
+	return checkResponse(resp)
+}
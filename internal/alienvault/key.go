@@ -0,0 +1,137 @@
+package alienvault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SensorKey is a key used to activate a sensor. The ID is traditionally used as an auth code to activate a sensor using the web UI.
+type SensorKey struct {
+	ID        string `json:"id"`
+	Consumed  bool
+	CreatedAt int     `json:"createdAt"`
+	ExpiresAt int     `json:"expires"`
+	NodeID    *string `json:"nodeId"`
+}
+
+// CreateSensorKey will create a new key used to activate a sensor. However, if the useExisting option is used, and an unused key already exists, this will be returned instead.
+func (client *Client) CreateSensorKey() (*SensorKey, error) {
+	return client.CreateSensorKeyWithContext(context.Background())
+}
+
+// CreateSensorKeyWithContext will create a new key used to activate a sensor. However, if the useExisting option is used, and an unused key already exists, this will be returned instead.
+func (client *Client) CreateSensorKeyWithContext(ctx context.Context) (*SensorKey, error) {
+
+	if err := client.checkWritable("sensor key", "create"); err != nil {
+		return nil, err
+	}
+
+	req, err := client.createRequest(ctx, "POST", "/sensors/key", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var key SensorKey
+	if err := json.NewDecoder(resp.Body).Decode(&key); err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// GetSensorKeys returns a list of all sensor keys on the account
+func (client *Client) GetSensorKeys() ([]SensorKey, error) {
+	return client.GetSensorKeysWithContext(context.Background())
+}
+
+// GetSensorKeysWithContext returns a list of all sensor keys on the account
+func (client *Client) GetSensorKeysWithContext(ctx context.Context) ([]SensorKey, error) {
+
+	req, err := client.createRequest(ctx, "GET", "/sensors/key", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := client.getList(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []SensorKey
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// GetSensorKey returns a particular sensor key identified by the supplied id
+func (client *Client) GetSensorKey(id string) (*SensorKey, error) {
+	return client.GetSensorKeyWithContext(context.Background(), id)
+}
+
+// GetSensorKeyWithContext returns a particular sensor key identified by the supplied id
+func (client *Client) GetSensorKeyWithContext(ctx context.Context, id string) (*SensorKey, error) {
+
+	// There is no GET for a singular key in the AV API atm
+
+	keys, err := client.GetSensorKeysWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key.ID == id {
+			return &key, nil
+		}
+	}
+
+	// if the key is not found mark it as consumed in the returned value, as keys are only available temporarily
+	return &SensorKey{
+		ID:       id,
+		Consumed: true,
+	}, nil
+}
+
+// DeleteSensorKey deletes a particular sensor key as identified by the supplied id. A *NotFoundError is returned if the
+// key no longer exists.
+func (client *Client) DeleteSensorKey(key *SensorKey) error {
+	return client.DeleteSensorKeyWithContext(context.Background(), key)
+}
+
+// DeleteSensorKeyWithContext deletes a particular sensor key as identified by the supplied id. A *NotFoundError is
+// returned if the key no longer exists.
+func (client *Client) DeleteSensorKeyWithContext(ctx context.Context, key *SensorKey) error {
+
+	if err := client.checkWritable("sensor key", "delete"); err != nil {
+		return err
+	}
+
+	req, err := client.createRequest(ctx, "DELETE", fmt.Sprintf("/sensors/key/%s", key.ID), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &NotFoundError{Resource: "sensor key", ID: key.ID}
+	}
+
+	return checkResponse(resp)
+}
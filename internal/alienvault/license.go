@@ -0,0 +1,190 @@
+package alienvault
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// License is an AV license subscription
+type License struct {
+	ControlNodeLimit int   `json:"controlNodesAllowed"`
+	SensorNodeLimit  int   `json:"sensorNodesAllowed"`
+	MonthlyStorageKB int64 `json:"monthlyKBStorage"`
+	Expiration       int64 `json:"expiration"`
+}
+
+// IsExpired returns true if the license in use has expired
+func (license *License) IsExpired() bool {
+	return time.Unix(license.Expiration, 0).Before(time.Now())
+}
+
+// GetLicense returns the license in use by the current account
+func (client *Client) GetLicense() (*License, error) {
+	return client.GetLicenseWithContext(context.Background())
+}
+
+// GetLicenseWithContext returns the license in use by the current account
+func (client *Client) GetLicenseWithContext(ctx context.Context) (*License, error) {
+
+	req, err := client.createRequest(ctx, "GET", "/license", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	license := License{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&license); err != nil {
+		return nil, err
+	}
+
+	return &license, nil
+}
+
+// HasSensorAvailability tells us whether we have room to create new sensors using the current license
+func (client *Client) HasSensorAvailability() (bool, error) {
+	return client.HasSensorAvailabilityWithContext(context.Background())
+}
+
+// HasSensorAvailabilityWithContext tells us whether we have room to create new sensors using the current license
+func (client *Client) HasSensorAvailabilityWithContext(ctx context.Context) (bool, error) {
+
+	sensors, err := client.GetSensorsWithContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	license, err := client.GetLicenseWithContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return len(sensors) < license.SensorNodeLimit, nil
+}
+
+// HasSensorKeyAvailability tells us whether we have room to create new sensor keys using the current license
+func (client *Client) HasSensorKeyAvailability() (bool, error) {
+	return client.HasSensorKeyAvailabilityWithContext(context.Background())
+}
+
+// HasSensorKeyAvailabilityWithContext tells us whether we have room to create new sensor keys using the current license
+func (client *Client) HasSensorKeyAvailabilityWithContext(ctx context.Context) (bool, error) {
+
+	sensors, err := client.GetSensorsWithContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	keys, err := client.GetSensorKeysWithContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	license, err := client.GetLicenseWithContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return len(sensors)+len(keys) < license.SensorNodeLimit, nil
+}
+
+// SensorReservation is the room left on the license once the sensors reserved via ReserveSensorSlots are counted
+type SensorReservation struct {
+	License   *License // License is the license in use
+	InUse     int      // InUse is the number of sensors and unused sensor keys when the first reservation was made
+	Sweepable int      // Sweepable is how many of those sensors this client would sweep before creating a sensor
+	Reserved  int      // Reserved is the total number of slots reserved so far
+}
+
+// sensorReservations tracks the slots reserved on the license by a client. What is in use is only looked at once, so
+// that creating what was reserved doesn't count it twice.
+type sensorReservations struct {
+	lock       sync.Mutex
+	counted    bool
+	license    *License
+	inUse      int
+	sweepable  int
+	sweeping   int // slots reserved for sensors which sweep before being created
+	unsweeping int // slots reserved for sensors and keys which don't sweep
+}
+
+// ReserveSensorSlots reserves room on the license for the given number of sensors or sensor keys which are planned to
+// be created, and returns whether the license has room for everything reserved so far. Pass sweeps as true if dead
+// sensors will be swept before they are created, so that the slots of those sensors can be counted as free.
+func (client *Client) ReserveSensorSlots(ctx context.Context, count int, sweeps bool) (*SensorReservation, bool, error) {
+
+	reservations := &client.reservations
+	reservations.lock.Lock()
+	defer reservations.lock.Unlock()
+
+	if !reservations.counted {
+		if err := client.countSensorSlots(ctx); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if sweeps {
+		reservations.sweeping += count
+	} else {
+		reservations.unsweeping += count
+	}
+
+	free := reservations.license.SensorNodeLimit - reservations.inUse
+	fits := reservations.unsweeping <= free && reservations.unsweeping+reservations.sweeping <= free+reservations.sweepable
+
+	return &SensorReservation{
+		License:   reservations.license,
+		InUse:     reservations.inUse,
+		Sweepable: reservations.sweepable,
+		Reserved:  reservations.unsweeping + reservations.sweeping,
+	}, fits, nil
+}
+
+// countSensorSlots counts the slots of the license in use by sensors and unused sensor keys. The caller must hold the
+// reservations lock.
+func (client *Client) countSensorSlots(ctx context.Context) error {
+
+	license, err := client.GetLicenseWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	sensors, err := client.GetSensorsWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err := client.GetSensorKeysWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	reservations := &client.reservations
+	reservations.license = license
+	reservations.inUse = len(sensors)
+	reservations.sweepable = 0
+	for _, sensor := range sensors {
+		if sensor.Status == SensorStatusConnectionLost && client.Owns(sensor.OwnershipTag) {
+			reservations.sweepable++
+		}
+	}
+	for _, key := range keys {
+		if key.NodeID == nil {
+			reservations.inUse++
+		}
+	}
+	reservations.counted = true
+
+	return nil
+}
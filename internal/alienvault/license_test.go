@@ -0,0 +1,62 @@
+package alienvault
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/avtest"
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+func TestReserveSensorSlots(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	server.SetLicense(avtest.License{SensorNodeLimit: 5, Expiration: time.Now().Add(time.Hour).Unix()})
+	server.AddSensor(avtest.Sensor{Name: "alive"})
+	server.AddSensor(avtest.Sensor{Name: "ours", Description: "dead [terraform:unit-test]", Status: "Connection lost"})
+	server.AddSensor(avtest.Sensor{Name: "theirs", Description: "dead [terraform:other]", Status: "Connection lost"})
+	server.AddSensorKey()
+
+	client := New(server.FQDN(), Credentials{Username: avtest.Username, Password: avtest.Password}, true, 0, WithOwnershipTag("unit-test"))
+
+	ctx := context.Background()
+
+	reservation, fits, err := client.ReserveSensorSlots(ctx, 1, false)
+	require.Nil(t, err)
+	assert.Assert(t, fits)
+	assert.Equal(t, 4, reservation.InUse)
+	assert.Equal(t, 1, reservation.Sweepable)
+	assert.Equal(t, 1, reservation.Reserved)
+
+	// what is in use is only counted once, so creating what was reserved doesn't count it twice
+	server.AddSensorKey()
+
+	reservation, fits, err = client.ReserveSensorSlots(ctx, 0, false)
+	require.Nil(t, err)
+	assert.Assert(t, fits)
+	assert.Equal(t, 4, reservation.InUse)
+
+	// only sensors which sweep can use the slot of the dead sensor
+	_, fits, err = client.ReserveSensorSlots(ctx, 1, false)
+	require.Nil(t, err)
+	assert.Assert(t, !fits)
+
+	client = New(server.FQDN(), Credentials{Username: avtest.Username, Password: avtest.Password}, true, 0, WithOwnershipTag("unit-test"))
+
+	reservation, fits, err = client.ReserveSensorSlots(ctx, 0, false)
+	require.Nil(t, err)
+	assert.Assert(t, fits)
+	assert.Equal(t, 5, reservation.InUse)
+
+	_, fits, err = client.ReserveSensorSlots(ctx, 1, true)
+	require.Nil(t, err)
+	assert.Assert(t, fits)
+
+	_, fits, err = client.ReserveSensorSlots(ctx, 1, true)
+	require.Nil(t, err)
+	assert.Assert(t, !fits)
+}
@@ -0,0 +1,131 @@
+package alienvault
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithRateLimit limits the client to the given number of requests per second, using a token bucket which allows up to
+// a second's worth of requests to be sent in a burst. A rate of zero or less disables the limit.
+func WithRateLimit(requestsPerSecond float64) Option {
+	return func(client *Client) {
+		if requestsPerSecond <= 0 {
+			client.limiter = nil
+			return
+		}
+		client.limiter = newRateLimiter(requestsPerSecond, int(math.Ceil(requestsPerSecond)))
+	}
+}
+
+// WithMaxConcurrentRequests limits the number of requests the client will have in flight at once. A limit of zero or
+// less disables the limit.
+func WithMaxConcurrentRequests(max int) Option {
+	return func(client *Client) {
+		if max <= 0 {
+			client.inFlight = nil
+			return
+		}
+		client.inFlight = make(chan struct{}, max)
+	}
+}
+
+// send sends a single request over the wire, waiting for the rate limiter and a free concurrency slot first
+func (client *Client) send(req *http.Request) (*http.Response, error) {
+
+	ctx := req.Context()
+
+	if client.limiter != nil {
+		if err := client.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if client.inFlight != nil {
+		select {
+		case client.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	resp, err := client.httpClient.Do(req)
+
+	if client.inFlight != nil {
+		if err != nil {
+			<-client.inFlight
+			return nil, err
+		}
+		// downloading the larger lists is much of the load we are limiting, so the slot is held until the body is closed
+		resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { <-client.inFlight }}
+	}
+
+	return resp, err
+}
+
+// releasingBody is a response body which calls release once it is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (body *releasingBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.once.Do(body.release)
+	return err
+}
+
+// rateLimiter is a token bucket, implemented as a generic cell rate algorithm: rather than counting tokens we track the
+// theoretical time at which the bucket will next be full, and make callers wait if sending now would overdraw it.
+type rateLimiter struct {
+	lock      sync.Mutex
+	interval  time.Duration // interval is the time taken for a single token to be added to the bucket
+	tolerance time.Duration // tolerance is how far ahead of schedule we allow requests to run, which gives us the burst size
+	tat       time.Time     // tat is the theoretical arrival time of the next request if requests were evenly spaced
+}
+
+func newRateLimiter(requestsPerSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	interval := time.Duration(float64(time.Second) / requestsPerSecond)
+	return &rateLimiter{
+		interval:  interval,
+		tolerance: interval * time.Duration(burst-1),
+	}
+}
+
+// reserve takes a token from the bucket, and returns how long the caller must wait before using it
+func (limiter *rateLimiter) reserve(now time.Time) time.Duration {
+
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+
+	tat := limiter.tat
+	if tat.Before(now) {
+		tat = now
+	}
+
+	wait := tat.Sub(now) - limiter.tolerance
+	if wait < 0 {
+		wait = 0
+	}
+
+	limiter.tat = tat.Add(limiter.interval)
+	return wait
+}
+
+// wait blocks until the caller is allowed to send a request, or the context is done
+func (limiter *rateLimiter) wait(ctx context.Context) error {
+
+	wait := limiter.reserve(time.Now())
+	if wait == 0 {
+		return nil
+	}
+
+	return sleep(ctx, wait)
+}
@@ -0,0 +1,120 @@
+package alienvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+func TestRateLimiterReservations(t *testing.T) {
+
+	limiter := newRateLimiter(10, 2)
+	now := time.Now()
+
+	// the bucket starts full, so we can burst
+	assert.Equal(t, time.Duration(0), limiter.reserve(now))
+	assert.Equal(t, time.Duration(0), limiter.reserve(now))
+
+	// then we are limited to the configured rate
+	assert.Equal(t, time.Millisecond*100, limiter.reserve(now))
+	assert.Equal(t, time.Millisecond*200, limiter.reserve(now))
+
+	// after a quiet period the bucket refills
+	later := now.Add(time.Second)
+	assert.Equal(t, time.Duration(0), limiter.reserve(later))
+	assert.Equal(t, time.Duration(0), limiter.reserve(later))
+	assert.Equal(t, time.Millisecond*100, limiter.reserve(later))
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+
+	var inFlight, maxInFlight int32
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/1.0/license" {
+			return
+		}
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond * 20)
+		fmt.Fprint(w, `{"sensorNodesAllowed":2}`)
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t, ts, WithMaxConcurrentRequests(2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetLicense()
+			require.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Assert(t, atomic.LoadInt32(&maxInFlight) <= 2)
+}
+
+func TestMaxConcurrentRequestsCoversBodies(t *testing.T) {
+
+	ts := newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"sensorNodesAllowed":2}`)
+	})
+	defer ts.Close()
+
+	// logging in needs every response it throws away to be closed, or it would wait on itself
+	client := newTestClient(t, ts, WithMaxConcurrentRequests(1))
+
+	req, err := client.createRequest(context.Background(), "GET", "/license", nil)
+	require.Nil(t, err)
+	resp, err := client.do(req)
+	require.Nil(t, err)
+
+	// the slot is held until the body has been closed
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	_, err = client.GetLicenseWithContext(ctx)
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+
+	require.Nil(t, resp.Body.Close())
+	require.Nil(t, resp.Body.Close())
+
+	_, err = client.GetLicense()
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(client.inFlight))
+}
+
+func TestRateLimit(t *testing.T) {
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"sensorNodesAllowed":2}`)
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t, ts, WithRateLimit(20))
+
+	// login uses 3 requests, so with a burst of 20 we should have to wait for at least 10 more tokens here
+	start := time.Now()
+	for i := 0; i < 27; i++ {
+		_, err := client.GetLicense()
+		require.Nil(t, err)
+	}
+	assert.Assert(t, time.Since(start) >= time.Millisecond*450)
+}
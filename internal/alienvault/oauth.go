@@ -0,0 +1,77 @@
+package alienvault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// tokens are refreshed this long before they actually expire, so a request never goes out with a token that is about to die
+const tokenExpiryDelta = time.Second * 30
+
+// oauthToken is a bearer token issued by the USM Anywhere OAuth2 endpoint for use with the public v2 API
+type oauthToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	expiry      time.Time
+}
+
+func (token *oauthToken) valid() bool {
+	return token != nil && token.AccessToken != "" && time.Now().Add(tokenExpiryDelta).Before(token.expiry)
+}
+
+// bearerToken returns a valid access token for the public v2 API, requesting a new one if the current token is missing or about to expire.
+func (client *Client) bearerToken(ctx context.Context) (string, error) {
+
+	client.tokenLock.Lock()
+	defer client.tokenLock.Unlock()
+
+	if client.token.valid() {
+		return client.token.AccessToken, nil
+	}
+
+	token, err := client.requestToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	client.token = token
+	return token.AccessToken, nil
+}
+
+// requestToken exchanges the client ID and secret for a new access token using the client credentials grant
+func (client *Client) requestToken(ctx context.Context) (*oauthToken, error) {
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("https://%s/api/2.0/oauth/token?grant_type=client_credentials", client.fqdn), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(client.creds.ClientID, client.creds.ClientSecret)
+
+	resp, err := client.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		d, _ := ioutil.ReadAll(resp.Body)
+		return nil, &AuthError{StatusCode: resp.StatusCode, Body: string(d)}
+	}
+
+	token := oauthToken{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("no access token was returned by the token endpoint")
+	}
+
+	token.expiry = time.Now().Add(time.Second * time.Duration(token.ExpiresIn))
+	return &token, nil
+}
@@ -0,0 +1,96 @@
+package alienvault
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+func newOAuthTestServer(t *testing.T, expiresIn int, tokenRequests *int32) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/2.0/oauth/token":
+			id, secret, ok := r.BasicAuth()
+			if !ok || id != "my-client" || secret != "my-secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			n := atomic.AddInt32(tokenRequests, 1)
+			fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":%d}`, n, expiresIn)
+		case "/api/2.0/sensors":
+			if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer token-") {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"_embedded":{"sensors":[{"id":"abc","name":"sensor-1"}]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestClientCredentialsAuth(t *testing.T) {
+
+	var tokenRequests int32
+	ts := newOAuthTestServer(t, 900, &tokenRequests)
+	defer ts.Close()
+
+	client := New(testFQDN(ts), Credentials{
+		ClientID:     "my-client",
+		ClientSecret: "my-secret",
+	}, true, 1)
+
+	require.Nil(t, client.Authenticate())
+
+	sensors, err := client.GetSensors()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(sensors))
+	assert.Equal(t, "abc", sensors[0].ID())
+
+	// token should be reused while it is still valid
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tokenRequests))
+
+	// internal API endpoints need a session, which we don't have
+	_, err = client.GetAWSBucketJobs()
+	require.NotNil(t, err)
+}
+
+func TestClientCredentialsTokenRefresh(t *testing.T) {
+
+	var tokenRequests int32
+	ts := newOAuthTestServer(t, 0, &tokenRequests)
+	defer ts.Close()
+
+	client := New(testFQDN(ts), Credentials{
+		ClientID:     "my-client",
+		ClientSecret: "my-secret",
+	}, true, 1)
+
+	require.Nil(t, client.Authenticate())
+
+	_, err := client.GetSensors()
+	require.Nil(t, err)
+
+	// the token expires immediately, so each use should trigger a refresh
+	assert.Equal(t, int32(2), atomic.LoadInt32(&tokenRequests))
+}
+
+func TestClientCredentialsInvalid(t *testing.T) {
+
+	var tokenRequests int32
+	ts := newOAuthTestServer(t, 900, &tokenRequests)
+	defer ts.Close()
+
+	client := New(testFQDN(ts), Credentials{
+		ClientID:     "my-client",
+		ClientSecret: "wrong",
+	}, true, 1)
+
+	require.NotNil(t, client.Authenticate())
+}
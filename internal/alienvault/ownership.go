@@ -0,0 +1,65 @@
+package alienvault
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ownershipMarkerPattern matches the marker appended to the description of objects created by a client with an
+// ownership tag
+var ownershipMarkerPattern = regexp.MustCompile(`\s*\[terraform:([^\]]+)\]$`)
+
+// WithOwnershipTag marks every sensor and job the client creates or updates as owned by the given tag, by adding a
+// marker to its description. The marker is hidden from descriptions returned by the client. Housekeeping which deletes
+// objects, such as sweeping dead sensors, only acts on objects carrying this tag. An empty tag disables marking, and
+// with it that housekeeping.
+func WithOwnershipTag(tag string) Option {
+	return func(client *Client) {
+		client.ownershipTag = tag
+	}
+}
+
+// OwnershipTag returns the tag the client marks the objects it creates with, if any
+func (client *Client) OwnershipTag() string {
+	return client.ownershipTag
+}
+
+// Owns returns true if an object with the given ownership tag belongs to this client
+func (client *Client) Owns(ownershipTag string) bool {
+	return client.ownershipTag != "" && ownershipTag == client.ownershipTag
+}
+
+// markOwned adds this client's ownership marker to a description, replacing any existing marker. A client without a tag
+// never claims an object, so it keeps the marker of the object's current owner, which is either in the description or
+// given as currentTag.
+func (client *Client) markOwned(description string, currentTag string) string {
+	description, tag := splitOwnershipMarker(description)
+	if client.ownershipTag != "" {
+		tag = client.ownershipTag
+	} else if tag == "" {
+		tag = currentTag
+	}
+	if tag == "" {
+		return description
+	}
+	return fmt.Sprintf("%s [terraform:%s]", description, tag)
+}
+
+// currentOwner returns the ownership tag of an object which is about to be updated, so that a client without a tag can
+// keep it. The tag is only looked up if it matters and isn't already known.
+func (client *Client) currentOwner(knownTag string, lookup func() (string, error)) (string, error) {
+	if client.ownershipTag != "" || knownTag != "" {
+		return knownTag, nil
+	}
+	return lookup()
+}
+
+// splitOwnershipMarker separates a description as stored in AlienVault into the description set by the user and the
+// ownership tag, if any
+func splitOwnershipMarker(description string) (string, string) {
+	match := ownershipMarkerPattern.FindStringSubmatchIndex(description)
+	if match == nil {
+		return description, ""
+	}
+	return description[:match[0]], description[match[2]:match[3]]
+}
@@ -0,0 +1,176 @@
+package alienvault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/avtest"
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+func TestSplitOwnershipMarker(t *testing.T) {
+
+	description, tag := splitOwnershipMarker("my sensor [terraform:prod-workspace]")
+	assert.Equal(t, "my sensor", description)
+	assert.Equal(t, "prod-workspace", tag)
+
+	description, tag = splitOwnershipMarker("my sensor")
+	assert.Equal(t, "my sensor", description)
+	assert.Equal(t, "", tag)
+
+	// only a trailing marker counts
+	description, tag = splitOwnershipMarker("[terraform:a] my sensor")
+	assert.Equal(t, "[terraform:a] my sensor", description)
+	assert.Equal(t, "", tag)
+}
+
+func TestMarkOwned(t *testing.T) {
+
+	client := New("example.com", Credentials{}, true, 1, WithOwnershipTag("mine"))
+	assert.Equal(t, "my sensor [terraform:mine]", client.markOwned("my sensor", ""))
+	assert.Equal(t, "my sensor [terraform:mine]", client.markOwned("my sensor [terraform:theirs]", ""))
+	assert.Equal(t, "my sensor [terraform:mine]", client.markOwned("my sensor", "theirs"))
+
+	// a client without a tag keeps the marker of the current owner
+	untagged := New("example.com", Credentials{}, true, 1)
+	assert.Equal(t, "my sensor", untagged.markOwned("my sensor", ""))
+	assert.Equal(t, "my sensor [terraform:theirs]", untagged.markOwned("my sensor [terraform:theirs]", ""))
+	assert.Equal(t, "my sensor [terraform:theirs]", untagged.markOwned("my sensor", "theirs"))
+	assert.Assert(t, !untagged.Owns(""))
+}
+
+// newOwnershipTestServer lists jobs and lost sensors with the given descriptions, and records the request bodies of
+// changes made
+func newOwnershipTestServer(t *testing.T, descriptions []string, changes *[]string) *httptest.Server {
+
+	var lock sync.Mutex
+
+	return newTestServer(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != "GET" {
+			b, _ := ioutil.ReadAll(r.Body)
+			lock.Lock()
+			*changes = append(*changes, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, b))
+			lock.Unlock()
+		}
+
+		switch {
+		case r.URL.Path == "/api/1.0/sensors" && r.Method == "GET":
+			var sensors []Sensor
+			for i, description := range descriptions {
+				sensors = append(sensors, Sensor{V1ID: fmt.Sprintf("s%d", i), Description: description, Status: SensorStatusConnectionLost})
+			}
+			require.Nil(t, json.NewEncoder(w).Encode(sensors))
+		case r.URL.Path == "/api/1.0/scheduler" && r.Method == "GET":
+			var jobs []AWSBucketJob
+			for i, description := range descriptions {
+				j := AWSBucketJob{}
+				j.UUID = fmt.Sprintf("j%d", i)
+				j.Description = description
+				j.Action = JobActionMonitorBucket
+				jobs = append(jobs, j)
+			}
+			require.Nil(t, json.NewEncoder(w).Encode(jobs))
+		case r.URL.Path == "/api/1.0/scheduler" && r.Method == "POST":
+			fmt.Fprint(w, `{"uuid":"new"}`)
+		}
+	})
+}
+
+func TestSweepOnlyRemovesOwnedSensors(t *testing.T) {
+
+	var changes []string
+	ts := newOwnershipTestServer(t, []string{"by hand", "ours [terraform:mine]", "theirs [terraform:other]"}, &changes)
+	defer ts.Close()
+
+	client := newTestClient(t, ts, WithOwnershipTag("mine"))
+	swept, err := client.sweepSensors(context.Background())
+	require.Nil(t, err)
+
+	assert.Equal(t, 1, swept)
+	assert.DeepEqual(t, []string{"DELETE /api/1.0/sensors/s1 "}, changes)
+}
+
+func TestSweepWithoutOwnershipTagRemovesNothing(t *testing.T) {
+
+	var changes []string
+	ts := newOwnershipTestServer(t, []string{"by hand", "ours [terraform:mine]"}, &changes)
+	defer ts.Close()
+
+	client := newTestClient(t, ts)
+	swept, err := client.sweepSensors(context.Background())
+	require.Nil(t, err)
+
+	assert.Equal(t, 0, swept)
+	assert.Equal(t, 0, len(changes))
+}
+
+func TestJobsAreMarkedAsOwned(t *testing.T) {
+
+	var changes []string
+	ts := newOwnershipTestServer(t, []string{"ours [terraform:mine]"}, &changes)
+	defer ts.Close()
+
+	client := newTestClient(t, ts, WithOwnershipTag("mine"))
+
+	j := &AWSBucketJob{}
+	j.Description = "a job"
+	require.Nil(t, client.CreateAWSBucketJob(j))
+	require.Equal(t, 1, len(changes))
+	assert.Assert(t, strings.Contains(changes[0], `"description":"a job [terraform:mine]"`))
+
+	// the caller's job is left as it was
+	assert.Equal(t, "a job", j.Description)
+
+	// and the marker is hidden when reading
+	existing, err := client.GetAWSBucketJob("j0")
+	require.Nil(t, err)
+	assert.Equal(t, "ours", existing.Description)
+	assert.Equal(t, "mine", existing.OwnershipTag)
+}
+
+func TestUpdateWithoutOwnershipTagKeepsMarker(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	tagged := New(server.FQDN(), Credentials{Username: avtest.Username, Password: avtest.Password}, true, 0, WithOwnershipTag("theirs"))
+	untagged := New(server.FQDN(), Credentials{Username: avtest.Username, Password: avtest.Password}, true, 0)
+
+	sensor := server.AddSensor(avtest.Sensor{Name: "shared", Description: "shared [terraform:theirs]"})
+	require.Nil(t, untagged.UpdateSensor(&Sensor{V1ID: sensor.V1ID, V2ID: sensor.V2ID, Name: "shared", Description: "updated"}))
+
+	updated, ok := server.Sensor(sensor.V1ID)
+	require.True(t, ok)
+	assert.Equal(t, "updated [terraform:theirs]", updated.Description)
+
+	bucketJob := &AWSBucketJob{}
+	bucketJob.Name = "bucket"
+	bucketJob.Description = "shared"
+	require.Nil(t, tagged.CreateAWSBucketJob(bucketJob))
+	bucketJob.Description = "updated"
+	require.Nil(t, untagged.UpdateAWSBucketJob(bucketJob))
+
+	job, ok := server.Job(bucketJob.UUID)
+	require.True(t, ok)
+	assert.Equal(t, "updated [terraform:theirs]", job["description"])
+
+	cloudWatchJob := &AWSCloudWatchJob{}
+	cloudWatchJob.Name = "cloudwatch"
+	cloudWatchJob.Description = "shared"
+	require.Nil(t, tagged.CreateAWSCloudWatchJob(cloudWatchJob))
+	cloudWatchJob.Description = "updated"
+	require.Nil(t, untagged.UpdateAWSCloudWatchJob(cloudWatchJob))
+
+	job, ok = server.Job(cloudWatchJob.UUID)
+	require.True(t, ok)
+	assert.Equal(t, "updated [terraform:theirs]", job["description"])
+}
@@ -0,0 +1,64 @@
+package alienvault
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// PollPolicy controls how often sensor creation checks whether the appliance or AlienVault has done what it is waiting
+// for. Checks start Interval apart, and the wait doubles after each one up to MaxInterval. Each wait is jittered, so
+// that sensors created at the same time don't poll in lockstep.
+type PollPolicy struct {
+	Interval    time.Duration // Interval is the wait before the second check. Zero uses the default.
+	MaxInterval time.Duration // MaxInterval is the longest wait between checks. Zero uses the default.
+}
+
+// DefaultPollPolicy is used for any part of a PollPolicy which is left unset
+var DefaultPollPolicy = PollPolicy{
+	Interval:    time.Second * 5,
+	MaxInterval: time.Minute,
+}
+
+// withDefaults returns the policy with any unset parts taken from DefaultPollPolicy
+func (policy PollPolicy) withDefaults() PollPolicy {
+	if policy.Interval <= 0 {
+		policy.Interval = DefaultPollPolicy.Interval
+	}
+	if policy.MaxInterval <= 0 {
+		policy.MaxInterval = DefaultPollPolicy.MaxInterval
+	}
+	if policy.MaxInterval < policy.Interval {
+		policy.MaxInterval = policy.Interval
+	}
+	return policy
+}
+
+// backoff returns the wait before the given check, not counting the first, without jitter
+func (policy PollPolicy) backoff(attempt int) time.Duration {
+	wait := policy.Interval
+	for i := 0; i < attempt && wait < policy.MaxInterval; i++ {
+		wait *= 2
+	}
+	if wait > policy.MaxInterval {
+		wait = policy.MaxInterval
+	}
+	return wait
+}
+
+// poller waits between the checks made while polling for a condition
+type poller struct {
+	policy  PollPolicy
+	attempt int
+}
+
+func newPoller(policy PollPolicy) *poller {
+	return &poller{policy: policy.withDefaults()}
+}
+
+// wait blocks until the next check is due, or the context ends. Waits are between half and all of the backoff.
+func (p *poller) wait(ctx context.Context) error {
+	wait := p.policy.backoff(p.attempt)
+	p.attempt++
+	return sleep(ctx, wait/2+time.Duration(rand.Int63n(int64(wait/2)+1)))
+}
@@ -0,0 +1,51 @@
+package alienvault
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestPollPolicyDefaults(t *testing.T) {
+
+	assert.Equal(t, DefaultPollPolicy, PollPolicy{}.withDefaults())
+	assert.Equal(t, PollPolicy{Interval: time.Second, MaxInterval: DefaultPollPolicy.MaxInterval}, PollPolicy{Interval: time.Second}.withDefaults())
+
+	// the max interval can't be less than the interval
+	assert.Equal(t, PollPolicy{Interval: time.Minute * 2, MaxInterval: time.Minute * 2}, PollPolicy{Interval: time.Minute * 2, MaxInterval: time.Minute}.withDefaults())
+}
+
+func TestPollPolicyBackoff(t *testing.T) {
+
+	policy := PollPolicy{Interval: time.Second, MaxInterval: time.Second * 5}
+
+	assert.Equal(t, time.Second, policy.backoff(0))
+	assert.Equal(t, time.Second*2, policy.backoff(1))
+	assert.Equal(t, time.Second*4, policy.backoff(2))
+	assert.Equal(t, time.Second*5, policy.backoff(3))
+	assert.Equal(t, time.Second*5, policy.backoff(100))
+}
+
+func TestPollerWaitIsJittered(t *testing.T) {
+
+	poller := newPoller(PollPolicy{Interval: time.Millisecond * 20, MaxInterval: time.Millisecond * 20})
+
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		assert.NilError(t, poller.wait(context.Background()))
+		elapsed := time.Since(start)
+		assert.Assert(t, elapsed >= time.Millisecond*10, "waited %s", elapsed)
+	}
+}
+
+func TestPollerWaitEndsWithContext(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := newPoller(PollPolicy{Interval: time.Hour}).wait(ctx)
+	assert.Assert(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,82 @@
+package alienvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+func TestReadOnlyRefusesChanges(t *testing.T) {
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.URL.Path != "/api/1.0/login" {
+			t.Errorf("unexpected %s %s in read-only mode", r.Method, r.URL.Path)
+		}
+		if r.URL.Path == "/api/1.0/scheduler" {
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	defer ts.Close()
+
+	client := New(testFQDN(ts), testCredentials, true, 1, WithReadOnly(true))
+
+	bucketJob := &AWSBucketJob{job: job{UUID: "abc"}}
+	cloudWatchJob := &AWSCloudWatchJob{job: job{UUID: "abc"}}
+	sensor := &Sensor{V1ID: "abc", V2ID: "def"}
+
+	tests := []struct {
+		resource  string
+		operation string
+		call      func() error
+	}{
+		{"AWS bucket job", "create", func() error { return client.CreateAWSBucketJob(&AWSBucketJob{}) }},
+		{"AWS bucket job", "update", func() error { return client.UpdateAWSBucketJob(bucketJob) }},
+		{"AWS bucket job", "delete", func() error { return client.DeleteAWSBucketJob(bucketJob) }},
+		{"AWS CloudWatch job", "create", func() error { return client.CreateAWSCloudWatchJob(&AWSCloudWatchJob{}) }},
+		{"AWS CloudWatch job", "update", func() error { return client.UpdateAWSCloudWatchJob(cloudWatchJob) }},
+		{"AWS CloudWatch job", "delete", func() error { return client.DeleteAWSCloudWatchJob(cloudWatchJob) }},
+		{"sensor key", "create", func() error { _, err := client.CreateSensorKey(); return err }},
+		{"sensor key", "delete", func() error { return client.DeleteSensorKey(&SensorKey{ID: "abc"}) }},
+		{"sensor", "update", func() error { return client.UpdateSensor(sensor) }},
+		{"sensor", "delete", func() error { return client.DeleteSensor(sensor) }},
+		{"sensor", "create", func() error {
+			return client.CreateSensorViaAppliance(context.Background(), &Sensor{}, ApplianceEndpoint{IP: net.ParseIP("127.0.0.1")}, PollPolicy{})
+		}},
+		{"dead sensors", "sweep", func() error { _, err := client.sweepSensors(context.Background()); return err }},
+	}
+
+	for _, test := range tests {
+		err := test.call()
+
+		var readOnly *ReadOnlyError
+		require.True(t, errors.As(err, &readOnly), "%s %s: %v", test.operation, test.resource, err)
+		assert.Equal(t, test.resource, readOnly.Resource)
+		assert.Equal(t, test.operation, readOnly.Operation)
+	}
+
+	// reads still work
+	_, err := client.GetAWSBucketJobs()
+	require.Nil(t, err)
+}
+
+func TestReadOnlyGuardsAllRequests(t *testing.T) {
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	client := New(testFQDN(ts), testCredentials, true, 1, WithReadOnly(true))
+
+	req, err := client.createRequest(context.Background(), "POST", "/scheduler", nil)
+	require.Nil(t, err)
+
+	_, err = client.do(req)
+	var readOnly *ReadOnlyError
+	require.True(t, errors.As(err, &readOnly))
+}
@@ -0,0 +1,143 @@
+package alienvault
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how the client retries requests which fail for transient reasons, such as a 502 from the
+// USM Anywhere front end or a connection reset.
+type RetryPolicy struct {
+	MaxRetries int           // MaxRetries is the number of times a failed request is retried. Zero disables retries.
+	MinWait    time.Duration // MinWait is the wait before the first retry. Subsequent waits double up to MaxWait.
+	MaxWait    time.Duration // MaxWait is the longest the client will wait between retries. A longer Retry-After ends the retries.
+}
+
+// DefaultRetryPolicy is used by clients which are not given a policy via WithRetryPolicy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	MinWait:    time.Second,
+	MaxWait:    time.Second * 30,
+}
+
+// WithRetryPolicy sets the policy used to retry requests which fail for transient reasons
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(client *Client) {
+		client.retryPolicy = policy
+	}
+}
+
+// retry sends the request using the send func, retrying on 429/5xx responses and network errors. Requests which are not
+// idempotent are only retried if they never reached the server, so that we never e.g. create the same job twice.
+func (client *Client) retry(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+
+	for attempt := 0; ; attempt++ {
+
+		resp, err := send(req)
+
+		if attempt >= client.retryPolicy.MaxRetries || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		wait := client.retryPolicy.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = retryAfter
+			}
+			// rather than retry before the server is ready, give up if we can't wait as long as it asked
+			if wait > client.retryPolicy.MaxWait || pastDeadline(req.Context(), wait) {
+				log.Printf("[DEBUG] %s %s failed with status %d, and won't be retried as the wait of %s is too long", req.Method, req.URL.Path, resp.StatusCode, wait)
+				return resp, nil
+			}
+			resp.Body.Close()
+			log.Printf("[DEBUG] %s %s failed with status %d, retrying in %s...", req.Method, req.URL.Path, resp.StatusCode, wait)
+		} else {
+			log.Printf("[DEBUG] %s %s failed: %s, retrying in %s...", req.Method, req.URL.Path, err, wait)
+		}
+
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+
+		req, err = client.rebuildRequest(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// pastDeadline returns whether waiting for the given time would take us past the deadline of the context
+func pastDeadline(ctx context.Context, wait time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && time.Now().Add(wait).After(deadline)
+}
+
+// backoff returns the exponential backoff to wait before the given retry attempt
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	wait := policy.MinWait
+	for i := 0; i < attempt && wait < policy.MaxWait; i++ {
+		wait *= 2
+	}
+	if wait > policy.MaxWait {
+		wait = policy.MaxWait
+	}
+	return wait
+}
+
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+
+	// we can't send the same body twice without being able to rewind it
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		return isIdempotent(req.Method) || neverReachedServer(err)
+	}
+
+	return (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500) && isIdempotent(req.Method)
+}
+
+// isIdempotent returns true if sending the request more than once has the same effect as sending it once. Our PATCH
+// requests always set absolute values, so they are safe to repeat.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// neverReachedServer returns true if the error shows that the connection could not be established, meaning the request
+// was never sent.
+func neverReachedServer(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which can be either a number of seconds or a HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Second * time.Duration(seconds), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
@@ -0,0 +1,189 @@
+package alienvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	MinWait:    time.Millisecond,
+	MaxWait:    time.Millisecond * 10,
+}
+
+// newFlakyTestServer returns a server which responds to the given path with the supplied status code for the first n requests
+func newFlakyTestServer(t *testing.T, path string, failures int32, status int, requests *int32) *httptest.Server {
+	return newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			return
+		}
+		if atomic.AddInt32(requests, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		switch path {
+		case "/api/1.0/license":
+			fmt.Fprint(w, `{"sensorNodesAllowed":2}`)
+		case "/api/1.0/scheduler":
+			fmt.Fprint(w, `{"uuid":"abc"}`)
+		}
+	})
+}
+
+func TestRetryIdempotentRequest(t *testing.T) {
+
+	var requests int32
+	ts := newFlakyTestServer(t, "/api/1.0/license", 2, http.StatusBadGateway, &requests)
+	defer ts.Close()
+
+	license, err := newTestClient(t, ts, WithRetryPolicy(testRetryPolicy)).GetLicense()
+	require.Nil(t, err)
+	assert.Equal(t, 2, license.SensorNodeLimit)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestRetryGivesUp(t *testing.T) {
+
+	var requests int32
+	ts := newFlakyTestServer(t, "/api/1.0/license", 100, http.StatusServiceUnavailable, &requests)
+	defer ts.Close()
+
+	_, err := newTestClient(t, ts, WithRetryPolicy(testRetryPolicy)).GetLicense()
+	require.NotNil(t, err)
+	assert.Equal(t, int32(testRetryPolicy.MaxRetries+1), atomic.LoadInt32(&requests))
+}
+
+func TestRetryNonIdempotentRequestIsNotRepeated(t *testing.T) {
+
+	var requests int32
+	ts := newFlakyTestServer(t, "/api/1.0/scheduler", 1, http.StatusBadGateway, &requests)
+	defer ts.Close()
+
+	err := newTestClient(t, ts, WithRetryPolicy(testRetryPolicy)).CreateAWSBucketJob(&AWSBucketJob{})
+	require.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestRetryNonIdempotentRequestWhenNeverSent(t *testing.T) {
+
+	var requests int32
+	ts := newFlakyTestServer(t, "/api/1.0/scheduler", 0, http.StatusOK, &requests)
+	defer ts.Close()
+
+	client := newTestClient(t, ts, WithRetryPolicy(testRetryPolicy))
+
+	req, err := client.createRequest(context.Background(), "POST", "/scheduler", strings.NewReader("{}"))
+	require.Nil(t, err)
+
+	attempts := 0
+	_, err = client.retry(req, func(r *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+		}
+		if attempts == 2 {
+			return nil, &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+
+	// the dial failure is retried, but the connection reset may have happened after the server received the request
+	require.NotNil(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+
+	var requests int32
+	ts := newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/1.0/license" {
+			return
+		}
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"sensorNodesAllowed":2}`)
+	})
+	defer ts.Close()
+
+	client := newTestClient(t, ts, WithRetryPolicy(testRetryPolicy))
+	client.retryPolicy.MaxWait = time.Second
+
+	start := time.Now()
+	_, err := client.GetLicense()
+	require.Nil(t, err)
+	assert.Assert(t, time.Since(start) >= time.Second)
+}
+
+func TestRetryGivesUpWhenRetryAfterIsTooLong(t *testing.T) {
+
+	var requests int32
+	ts := newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/1.0/license" {
+			return
+		}
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer ts.Close()
+
+	client := newTestClient(t, ts, WithRetryPolicy(testRetryPolicy))
+
+	// the server asks for longer than the policy allows
+	start := time.Now()
+	_, err := client.GetLicense()
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Assert(t, time.Since(start) < time.Second)
+
+	// or for longer than the caller can wait
+	client.retryPolicy.MaxWait = time.Hour * 2
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err = client.GetLicenseWithContext(ctx)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{MinWait: time.Second, MaxWait: time.Second * 5}
+	assert.Equal(t, time.Second, policy.backoff(0))
+	assert.Equal(t, time.Second*2, policy.backoff(1))
+	assert.Equal(t, time.Second*4, policy.backoff(2))
+	assert.Equal(t, time.Second*5, policy.backoff(3))
+	assert.Equal(t, time.Second*5, policy.backoff(30))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+
+	wait, ok := parseRetryAfter("7")
+	assert.Assert(t, ok)
+	assert.Equal(t, time.Second*7, wait)
+
+	_, ok = parseRetryAfter("")
+	assert.Assert(t, !ok)
+
+	_, ok = parseRetryAfter("soon")
+	assert.Assert(t, !ok)
+
+	wait, ok = parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	assert.Assert(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+}
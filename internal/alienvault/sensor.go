@@ -0,0 +1,677 @@
+package alienvault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Sensor is a machine which gathers event data from your infrastrcture and absorbs it into the AV system
+type Sensor struct {
+	// Annoyingly, AV have two fields ID and UUID which both appear to be a primary key - UUID is used in v1 calls, ID in v2
+	V1ID           string            `json:"uuid,omitempty"`
+	V2ID           string            `json:"id,omitempty"`
+	Name           string            `json:"name"`
+	Description    string            `json:"description"`
+	ActivationCode string            `json:"activation_code"`
+	Status         SensorStatus      `json:"status"`
+	SetupStatus    SensorSetupStatus `json:"setupStatus"`
+	Type           string            `json:"type"`
+	Version        string            `json:"version"`
+	LastConnected  int64             `json:"lastConnected"` // unix time at which the appliance was last connected, or zero if never
+	OwnershipTag   string            `json:"-"`             // the ownership tag of the client which created or last updated the sensor, if any
+}
+
+type sensorActivation struct {
+	//{"key":"${alienvault_sensor_key.main.id}","masterNode":"form3.alienvault.cloud","name":"${var.stack_name}-sensor","description":"${var.stack_name} sensor created by terraform"}
+	SensorKey   string `json:"key"`
+	MasterNode  string `json:"masterNode"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type applianceStatusResponse struct {
+	Status applianceStatus `json:"status"`
+}
+
+type v2SensorList struct {
+	Embedded v2InnerSensorList `json:"_embedded"`
+}
+type v2InnerSensorList struct {
+	Sensors []Sensor `json:"sensors"`
+}
+
+type applianceStatus string
+
+const (
+	applianceStatusNotConnected applianceStatus = "notConnected"
+)
+
+// SensorStatus refers to whether or not the sensor is ready for jobs. "Ready" indicates that this is so.
+type SensorStatus string
+
+const (
+	// SensorStatusReady indicates sensor is ready for configuration
+	SensorStatusReady SensorStatus = "Ready"
+	// SensorStatusConnectionLost refers to a sensor configuration which has lost contact with the actual appliance, possibly becuse the appliance no longer exists.
+	SensorStatusConnectionLost SensorStatus = "Connection lost"
+)
+
+type sensorSetupPatch struct {
+	SetupStatus SensorSetupStatus `json:"setupStatus"`
+}
+
+type sensorUpdatePatch struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// SensorSetupStatus refers to whether or not the sensor has had it's configuration finalised
+type SensorSetupStatus string
+
+const (
+	// SensorSetupStatusComplete indicates sensor has had it's configuration finalised
+	SensorSetupStatusComplete SensorSetupStatus = "Complete"
+)
+
+func (sensor *Sensor) ID() string {
+	// v2 API does not include v1 ID
+	if sensor.V1ID != "" {
+		return sensor.V1ID
+	}
+	return sensor.V2ID
+}
+
+// sensorPathID returns the ID used to address the sensor in the API version in use for sensor requests
+func (client *Client) sensorPathID(sensor *Sensor) string {
+	if client.publicAPIVersion() == 2 && sensor.V2ID != "" {
+		return sensor.V2ID
+	}
+	return sensor.ID()
+}
+
+// resolveSensorPathID returns the ID used to address the sensor in the API version in use for sensor requests. If only
+// one ID is known for the sensor, which may be the one used by the other API version, the sensor is looked up first.
+func (client *Client) resolveSensorPathID(ctx context.Context, sensor *Sensor) (string, error) {
+
+	if sensor.V1ID != "" && sensor.V2ID != "" && sensor.V1ID != sensor.V2ID {
+		return client.sensorPathID(sensor), nil
+	}
+
+	s, err := client.GetSensorWithContext(ctx, sensor.ID())
+	if err != nil {
+		return "", err
+	}
+
+	return client.sensorPathID(s), nil
+}
+
+// addV1SensorIDs fills in the v1 IDs of sensors listed by the v2 API, which only includes v2 IDs, so that sensors can
+// be found by either ID whichever version is in use. Jobs refer to sensors by their v1 ID.
+func (client *Client) addV1SensorIDs(ctx context.Context, sensors []Sensor) error {
+
+	req, err := client.createSessionRequest(ctx, internalAPIVersion, "GET", "/sensors", nil)
+	if err != nil {
+		return err
+	}
+
+	body, err := client.getList(req)
+	if err != nil {
+		return err
+	}
+
+	var v1Sensors []Sensor
+	if err := json.Unmarshal(body, &v1Sensors); err != nil {
+		return err
+	}
+
+	v1IDs := map[string]string{}
+	for _, s := range v1Sensors {
+		v1IDs[s.V2ID] = s.V1ID
+	}
+
+	for i := range sensors {
+		if sensors[i].V1ID == "" {
+			sensors[i].V1ID = v1IDs[sensors[i].V2ID]
+		}
+	}
+
+	return nil
+}
+
+// waitForSensorToBeReady blocks until the given sensor is ready. Pass a context with timeout to abort after a set time.
+func (client *Client) waitForSensorToBeReady(ctx context.Context, sensor *Sensor, poll PollPolicy) error {
+
+	// this usually takes 10-30 minutes, so the backoff soon settles at the max interval
+	poller := newPoller(poll)
+
+	for {
+
+		// the sensor status changes without any request from us, so we can't rely on a cached copy
+		client.cache.invalidate()
+
+		s, err := client.GetSensorWithContext(ctx, sensor.ID())
+		if err != nil {
+			return err
+		}
+
+		if s.Status == SensorStatusReady {
+			return nil
+		}
+
+		if err := poller.wait(ctx); err != nil {
+			return err
+		}
+	}
+
+}
+
+// sweepSensors deletes sensors owned by this client which have lost connection to their appliance, and returns how many
+// were deleted. Sensors created by anyone else are never touched.
+func (client *Client) sweepSensors(ctx context.Context) (int, error) {
+
+	if err := client.checkWritable("dead sensors", "sweep"); err != nil {
+		return 0, err
+	}
+
+	if client.ownershipTag == "" {
+		log.Printf("[DEBUG] no ownership tag is set, so no sensors can be swept")
+		return 0, nil
+	}
+
+	sensors, err := client.GetSensorsWithContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	swept := 0
+	for _, sensor := range sensors {
+		if sensor.Status == SensorStatusConnectionLost && client.Owns(sensor.OwnershipTag) {
+			if err := client.DeleteSensorWithContext(ctx, &sensor); err != nil {
+				return swept, err
+			}
+			swept++
+		}
+	}
+
+	return swept, nil
+}
+
+// waitForSensorKeyAvailability blocks until the license allows another sensor key to be created. AV sometimes takes a
+// while to free up the license slots of swept sensors, so if any were swept the license is polled until one is freed,
+// otherwise there is no point waiting.
+func (client *Client) waitForSensorKeyAvailability(ctx context.Context, swept int, poll PollPolicy) error {
+
+	poller := newPoller(poll)
+
+	for {
+
+		// the license is updated without any request from us, so we can't rely on a cached copy
+		client.cache.invalidate()
+
+		ok, err := client.HasSensorKeyAvailabilityWithContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+
+		if swept == 0 {
+			return fmt.Errorf("the AlienVault license in use does not allow creation of more sensors")
+		}
+
+		log.Printf("[DEBUG] waiting for the license slots of %d swept sensors to be freed...", swept)
+
+		if err := poller.wait(ctx); err != nil {
+			return fmt.Errorf("the AlienVault license in use does not allow creation of more sensors, even after sweeping %d dead sensors: %w", swept, err)
+		}
+	}
+}
+
+// GetSensor returns a specific sensor as identified by the id parameter
+func (client *Client) GetSensor(id string) (*Sensor, error) {
+	return client.GetSensorWithContext(context.Background(), id)
+}
+
+// GetSensorWithContext returns a specific sensor as identified by the id parameter
+func (client *Client) GetSensorWithContext(ctx context.Context, id string) (*Sensor, error) {
+
+	sensors, err := client.GetSensorsWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sensor := range sensors {
+		if sensor.V1ID == id || sensor.V2ID == id {
+			return &sensor, nil
+		}
+	}
+
+	return nil, &NotFoundError{Resource: "sensor", ID: id}
+}
+
+// GetSensors returns a list of all sensors
+func (client *Client) GetSensors() ([]Sensor, error) {
+	return client.GetSensorsWithContext(context.Background())
+}
+
+// GetSensorsWithContext returns a list of all sensors
+func (client *Client) GetSensorsWithContext(ctx context.Context) ([]Sensor, error) {
+
+	req, err := client.createPublicRequest(ctx, "GET", "/sensors", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := client.getList(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var sensors []Sensor
+
+	switch client.publicAPIVersion() {
+	case 1:
+		if err := json.Unmarshal(body, &sensors); err != nil {
+			return nil, err
+		}
+	case 2:
+		list := v2SensorList{}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, err
+		}
+		sensors = list.Embedded.Sensors
+		if client.v1SensorsAvailable {
+			if err := client.addV1SensorIDs(ctx, sensors); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported client version: %d", client.publicAPIVersion())
+	}
+
+	for i := range sensors {
+		sensors[i].Description, sensors[i].OwnershipTag = splitOwnershipMarker(sensors[i].Description)
+	}
+
+	return sensors, nil
+}
+
+// CreateSensorViaAppliance creates a new sensor via the sensor appliance at the given endpoint, checking on the progress
+// of each step as often as the given policy allows
+func (client *Client) CreateSensorViaAppliance(ctx context.Context, sensor *Sensor, appliance ApplianceEndpoint, poll PollPolicy) error {
+
+	if err := client.checkWritable("sensor", "create"); err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] sweeping dead sensors...")
+
+	// remove any dead sensors to free up license slots
+	swept, err := client.sweepSensors(ctx)
+	if err != nil {
+		return err
+	}
+
+	activationCode := sensor.ActivationCode
+
+	if activationCode == "" {
+
+		log.Printf("[DEBUG] checking license...")
+		if err := client.waitForSensorKeyAvailability(ctx, swept, poll); err != nil {
+			return err
+		}
+
+		log.Printf("[DEBUG] creating sensor key...")
+
+		// first of all we need to make sure we can get our hands on an ath code (aka sensor key) to activate our new sensor
+		// this may not be possible if we've maxed out the number of sensors on our license, so attempt this first and fail fast
+		key, err := client.CreateSensorKeyWithContext(ctx)
+		if err != nil {
+			return err
+		}
+		// ensure the key we create gets deleted if it isn't used for any reason
+		defer func() {
+			// the context may have expired by now, but the key should still be cleaned up
+			cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second*30)
+			defer cancel()
+			_ = client.DeleteSensorKeyWithContext(cleanupCtx, key)
+		}()
+
+		activationCode = key.ID
+	}
+
+	log.Printf("[DEBUG] waiting for appliance to be created at %s...", appliance.url(""))
+
+	// wait until the sensor appliance has been created and is running an AV API over HTTP
+	if err := client.waitForSensorApplianceCreation(ctx, appliance, poll); err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] activating sensor appliance...")
+
+	// the sensor appliance is alive! cool, now we can activate it with our auth code
+	if err := client.activateSensorAppliance(ctx, appliance, sensor, activationCode, poll); err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] waiting for sensor to be registered...")
+
+	// the appliance consumes the key when it registers the sensor, and the key then refers to the sensor it created
+	createdSensor, err := client.waitForSensorRegistration(ctx, activationCode, poll)
+	if err != nil {
+		return err
+	}
+
+	return client.finishSensorSetup(ctx, sensor, createdSensor, poll)
+}
+
+// CreateSensorViaExternalActivation waits for an appliance to activate itself using the activation code of the sensor,
+// e.g. one passed to it in its user data, and then completes the setup of the sensor it registered. No requests are
+// made to the appliance, so it doesn't need to be reachable. Progress is checked as often as the given policy allows.
+func (client *Client) CreateSensorViaExternalActivation(ctx context.Context, sensor *Sensor, poll PollPolicy) error {
+
+	if err := client.checkWritable("sensor", "create"); err != nil {
+		return err
+	}
+
+	if sensor.ActivationCode == "" {
+		return fmt.Errorf("an activation code is required to create a sensor which is activated externally")
+	}
+
+	log.Printf("[DEBUG] waiting for an appliance to register a sensor with the activation code...")
+
+	createdSensor, err := client.waitForSensorRegistration(ctx, sensor.ActivationCode, poll)
+	if err != nil {
+		return err
+	}
+
+	sensor.V1ID = createdSensor.V1ID
+	sensor.V2ID = createdSensor.V2ID
+
+	log.Printf("[DEBUG] naming sensor...")
+
+	// the appliance registered the sensor with whatever name and description it was given, which we replace
+	if err := client.UpdateSensorWithContext(ctx, sensor); err != nil {
+		return err
+	}
+
+	return client.finishSensorSetup(ctx, sensor, createdSensor, poll)
+}
+
+// finishSensorSetup completes the setup of a sensor which has just been registered by an appliance, and waits for it to
+// be ready
+func (client *Client) finishSensorSetup(ctx context.Context, sensor *Sensor, createdSensor *Sensor, poll PollPolicy) error {
+
+	log.Printf("[DEBUG] completing setup...")
+
+	// we need the ID of the created sensor to complete setup
+	sensor.V1ID = createdSensor.V1ID
+	sensor.V2ID = createdSensor.V2ID
+
+	if err := client.completeSetup(ctx, createdSensor); err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] waiting for sensor to be live...")
+
+	return client.waitForSensorToBeReady(ctx, sensor, poll)
+}
+
+// waitForSensorRegistration blocks until the sensor key with the given ID has been consumed by an appliance, and returns
+// the sensor which the appliance registered with it. Pass a context with timeout to abort after a set time.
+func (client *Client) waitForSensorRegistration(ctx context.Context, keyID string, poll PollPolicy) (*Sensor, error) {
+
+	poller := newPoller(poll)
+
+	for {
+
+		// the key is consumed without any request from us, so we can't rely on a cached copy
+		client.cache.invalidate()
+
+		key, err := client.GetSensorKeyWithContext(ctx, keyID)
+		if err != nil {
+			return nil, err
+		}
+
+		if key.NodeID != nil {
+			sensor, err := client.GetSensorWithContext(ctx, *key.NodeID)
+			var notFound *NotFoundError
+			if !errors.As(err, &notFound) {
+				return sensor, err
+			}
+			// the key can refer to the sensor shortly before it is listed
+		} else if key.Consumed {
+			return nil, fmt.Errorf("sensor key %s was removed before the sensor activated with it could be identified", keyID)
+		}
+
+		if err := poller.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// waitForSensorApplianceCreation blocks until the appliance at the given endpoint responds over HTTP, and is waiting to be
+// activated. Pass a context with timeout to abort after a set time.
+func (client *Client) waitForSensorApplianceCreation(ctx context.Context, appliance ApplianceEndpoint, poll PollPolicy) error {
+	anonymousClient, closeClient, err := client.newApplianceClient(appliance)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	url := appliance.url("/api/1.0/status")
+
+	poller := newPoller(poll)
+
+	//keep hitting the sensor appliance until it responds over http, or until context ends
+	for {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := anonymousClient.Do(req)
+		if err == nil {
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			if resp.StatusCode == 200 {
+				status := applianceStatusResponse{}
+				if err := json.Unmarshal(b, &status); err == nil {
+					if status.Status == applianceStatusNotConnected {
+						break
+					} else {
+						return fmt.Errorf("Unexpected appliance status: %s", status.Status)
+					}
+				}
+
+			} else {
+				log.Printf("[ERROR] Status response code: %d", resp.StatusCode)
+			}
+		} else {
+			log.Printf("[ERROR] Status check failed: %s", err)
+		}
+
+		if err := poller.wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (client *Client) activateSensorAppliance(ctx context.Context, appliance ApplianceEndpoint, sensor *Sensor, activationCode string, poll PollPolicy) error {
+	anonymousClient, closeClient, err := client.newApplianceClient(appliance)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	activationPayload := sensorActivation{
+		Name:        sensor.Name,
+		Description: client.markOwned(sensor.Description, ""),
+		SensorKey:   activationCode,
+		MasterNode:  client.fqdn,
+	}
+
+	poller := newPoller(poll)
+
+	var lastErr error
+
+	for {
+		b := new(bytes.Buffer)
+		if err := json.NewEncoder(b).Encode(activationPayload); err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", appliance.url("/api/1.0/connect"), b)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Origin", appliance.url(""))
+		req.Header.Set("Referer", appliance.url("/"))
+		req.Header.Set("Content-Type", "application/json;charset=UTF-8")
+
+		if resp, err := anonymousClient.Do(req); err == nil {
+			defer resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				break
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			lastErr = fmt.Errorf("activation was rejected with status %d: %s", resp.StatusCode, body)
+		} else if ctx.Err() == nil {
+			// an attempt cut short by the context tells us nothing about why activation is failing
+			lastErr = err
+		}
+		log.Printf("[ERROR] Activation failed: %s", lastErr)
+
+		if err := poller.wait(ctx); err != nil {
+			if lastErr == nil {
+				return err
+			}
+			return fmt.Errorf("failed to activate sensor appliance: %w (last attempt: %s)", err, lastErr)
+		}
+	}
+
+	return nil
+}
+
+// UpdateSensor updates an existing sensor
+func (client *Client) UpdateSensor(sensor *Sensor) error {
+	return client.UpdateSensorWithContext(context.Background(), sensor)
+}
+
+// UpdateSensorWithContext updates an existing sensor
+func (client *Client) UpdateSensorWithContext(ctx context.Context, sensor *Sensor) error {
+	if err := client.checkWritable("sensor", "update"); err != nil {
+		return err
+	}
+
+	owner, err := client.currentOwner(sensor.OwnershipTag, func() (string, error) {
+		current, err := client.GetSensorWithContext(ctx, sensor.ID())
+		if err != nil {
+			return "", err
+		}
+		return current.OwnershipTag, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sensorPatch := sensorUpdatePatch{
+		Name:        sensor.Name,
+		Description: client.markOwned(sensor.Description, owner),
+	}
+
+	data, err := json.Marshal(sensorPatch)
+	if err != nil {
+		return err
+	}
+
+	id, err := client.resolveSensorPathID(ctx, sensor)
+	if err != nil {
+		return err
+	}
+
+	req, err := client.createPublicRequest(ctx, "PATCH", fmt.Sprintf("/sensors/%s", id), bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkResponse(resp)
+}
+
+// completeSetup marks a sensor as having it's setup finalised
+func (client *Client) completeSetup(ctx context.Context, sensor *Sensor) error {
+
+	if err := client.checkWritable("sensor", "complete setup of"); err != nil {
+		return err
+	}
+
+	sensorPatch := sensorSetupPatch{
+		SetupStatus: SensorSetupStatusComplete,
+	}
+
+	data, err := json.Marshal(sensorPatch)
+	if err != nil {
+		return err
+	}
+
+	req, err := client.createPublicRequest(ctx, "PATCH", fmt.Sprintf("/sensors/%s", client.sensorPathID(sensor)), bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkResponse(resp)
+}
+
+// DeleteSensor deletes an existing sensor
+func (client *Client) DeleteSensor(sensor *Sensor) error {
+	return client.DeleteSensorWithContext(context.Background(), sensor)
+}
+
+// DeleteSensorWithContext deletes an existing sensor
+func (client *Client) DeleteSensorWithContext(ctx context.Context, sensor *Sensor) error {
+
+	if err := client.checkWritable("sensor", "delete"); err != nil {
+		return err
+	}
+
+	id, err := client.resolveSensorPathID(ctx, sensor)
+	if err != nil {
+		return err
+	}
+
+	req, err := client.createPublicRequest(ctx, "DELETE", fmt.Sprintf("/sensors/%s", id), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkResponse(resp)
+}
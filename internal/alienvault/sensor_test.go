@@ -0,0 +1,308 @@
+package alienvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/avtest"
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+// testPollPolicy shrinks every wait in the activation workflow so it can run against an emulated appliance
+var testPollPolicy = PollPolicy{Interval: time.Millisecond * 5, MaxInterval: time.Millisecond * 20}
+
+func newApplianceTestClient(server *avtest.Server) *Client {
+	client := New(server.FQDN(), Credentials{Username: avtest.Username, Password: avtest.Password}, true, 0,
+		WithOwnershipTag("unit-test"),
+	)
+	return client
+}
+
+// newApplianceEndpoint returns the endpoint the emulated appliance listens on, trusting its CA if it serves HTTPS
+func newApplianceEndpoint(appliance *avtest.Appliance) ApplianceEndpoint {
+	addr := appliance.Listener.Addr().(*net.TCPAddr)
+	return ApplianceEndpoint{
+		IP:        addr.IP,
+		Port:      addr.Port,
+		Scheme:    appliance.Scheme(),
+		CACertPEM: appliance.CACertPEM(),
+	}
+}
+
+func createSensorViaAppliance(t *testing.T, timeout time.Duration, options ...avtest.ApplianceOption) (*avtest.Server, *Sensor, error) {
+
+	server := avtest.NewServer()
+	t.Cleanup(server.Close)
+
+	appliance := avtest.NewAppliance(server, append([]avtest.ApplianceOption{avtest.WithTLS()}, options...)...)
+	t.Cleanup(appliance.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	sensor := &Sensor{Name: "sensor", Description: "created by a test"}
+	err := newApplianceTestClient(server).CreateSensorViaAppliance(ctx, sensor, newApplianceEndpoint(appliance), testPollPolicy)
+	return server, sensor, err
+}
+
+func TestCreateSensorViaAppliance(t *testing.T) {
+
+	server, sensor, err := createSensorViaAppliance(t, time.Second*5,
+		avtest.WithBootDelay(time.Millisecond*50),
+		avtest.WithRegistrationDelay(time.Millisecond*10),
+		avtest.WithReadyDelay(time.Millisecond*50),
+	)
+	require.Nil(t, err)
+
+	created, ok := server.Sensor(sensor.V1ID)
+	require.True(t, ok)
+	assert.Equal(t, "sensor", created.Name)
+	assert.Equal(t, "Ready", created.Status)
+	assert.Equal(t, "Complete", created.SetupStatus)
+	assert.Equal(t, created.V2ID, sensor.V2ID)
+
+	// the sensor is marked as ours, and the key it consumed is cleaned up
+	assert.Assert(t, strings.Contains(created.Description, "[terraform:unit-test]"))
+	assert.Equal(t, 0, len(server.SensorKeys()))
+}
+
+func TestCreateSensorViaApplianceWhichNeverBoots(t *testing.T) {
+
+	server, _, err := createSensorViaAppliance(t, time.Millisecond*200, avtest.WithFailure(avtest.ApplianceNeverBoots))
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+
+	// the key created for the sensor is not left behind
+	assert.Equal(t, 0, len(server.SensorKeys()))
+	assert.Equal(t, 0, len(server.Sensors()))
+}
+
+func TestCreateSensorViaApplianceWithWrongStatus(t *testing.T) {
+
+	_, _, err := createSensorViaAppliance(t, time.Second*5, avtest.WithFailure(avtest.ApplianceWrongStatus))
+	require.NotNil(t, err)
+	assert.ErrorContains(t, err, "Unexpected appliance status: upgrading")
+}
+
+func TestCreateSensorViaApplianceWhichRejectsActivation(t *testing.T) {
+
+	_, _, err := createSensorViaAppliance(t, time.Millisecond*200, avtest.WithFailure(avtest.ApplianceRejectsActivation))
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+	assert.ErrorContains(t, err, "activation rejected")
+}
+
+func TestCreateSensorViaApplianceWhichNeverBecomesReady(t *testing.T) {
+
+	server, sensor, err := createSensorViaAppliance(t, time.Millisecond*500, avtest.WithFailure(avtest.ApplianceNeverReady))
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+
+	// setup was completed, but the sensor is stuck
+	created, ok := server.Sensor(sensor.V1ID)
+	require.True(t, ok)
+	assert.Equal(t, "Complete", created.SetupStatus)
+	assert.Equal(t, "Connecting", created.Status)
+}
+
+func TestCreateSensorViaApplianceWaitsForRegistration(t *testing.T) {
+
+	server, sensor, err := createSensorViaAppliance(t, time.Second*5, avtest.WithRegistrationDelay(time.Millisecond*200))
+	require.Nil(t, err)
+
+	created, ok := server.Sensor(sensor.V1ID)
+	require.True(t, ok)
+	assert.Equal(t, "Ready", created.Status)
+}
+
+func TestCreateSensorsWithTheSameNameInParallel(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	// a sensor with the same name which is still being set up by someone else must be left alone
+	other := server.AddSensor(avtest.Sensor{Name: "sensor", Status: "Connecting", SetupStatus: "Pending"})
+
+	sensors := make([]*Sensor, 2)
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+
+	for i := range sensors {
+		appliance := avtest.NewAppliance(server, avtest.WithRegistrationDelay(time.Millisecond*time.Duration(50*i)))
+		defer appliance.Close()
+
+		sensors[i] = &Sensor{Name: "sensor"}
+		client := newApplianceTestClient(server)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+			errs[i] = client.CreateSensorViaAppliance(ctx, sensors[i], newApplianceEndpoint(appliance), testPollPolicy)
+		}(i)
+	}
+	wg.Wait()
+
+	require.Nil(t, errs[0])
+	require.Nil(t, errs[1])
+	assert.Assert(t, sensors[0].V1ID != sensors[1].V1ID)
+
+	for _, sensor := range sensors {
+		created, ok := server.Sensor(sensor.V1ID)
+		require.True(t, ok)
+		assert.Equal(t, "Ready", created.Status)
+	}
+
+	untouched, ok := server.Sensor(other.V1ID)
+	require.True(t, ok)
+	assert.Equal(t, "Pending", untouched.SetupStatus)
+}
+
+func TestCreateSensorViaApplianceWhenKeyIsRemoved(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	appliance := avtest.NewAppliance(server, avtest.WithRegistrationDelay(time.Second))
+	defer appliance.Close()
+
+	// once the key has been created, it disappears before the appliance registers a sensor with it
+	server.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if _, registered := appliance.SensorID(); r.Method != "GET" || r.URL.Path != "/api/1.0/sensors/key" || registered {
+			return false
+		}
+		for _, path := range server.Requests() {
+			if path == "POST /api/1.0/sensors/key" {
+				fmt.Fprint(w, `[]`)
+				return true
+			}
+		}
+		return false
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	err := newApplianceTestClient(server).CreateSensorViaAppliance(ctx, &Sensor{Name: "sensor"}, newApplianceEndpoint(appliance), testPollPolicy)
+	require.NotNil(t, err)
+	assert.ErrorContains(t, err, "was removed before the sensor activated with it could be identified")
+}
+
+func TestCreateSensorViaApplianceWaitsForSweptLicenseSlots(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	appliance := avtest.NewAppliance(server)
+	defer appliance.Close()
+
+	dead := server.AddSensor(avtest.Sensor{Name: "dead", Description: "old [terraform:unit-test]", Status: "Connection lost"})
+
+	// the license slot of the swept sensor is only freed a while after it is deleted
+	expiration := time.Now().Add(time.Hour).Unix()
+	server.SetLicense(avtest.License{SensorNodeLimit: 0, Expiration: expiration})
+	timer := time.AfterFunc(time.Millisecond*100, func() { server.SetLicense(avtest.License{SensorNodeLimit: 1, Expiration: expiration}) })
+	defer timer.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	sensor := &Sensor{Name: "sensor"}
+	require.Nil(t, newApplianceTestClient(server).CreateSensorViaAppliance(ctx, sensor, newApplianceEndpoint(appliance), testPollPolicy))
+
+	_, ok := server.Sensor(dead.V1ID)
+	assert.Assert(t, !ok)
+	_, ok = server.Sensor(sensor.V1ID)
+	assert.Assert(t, ok)
+
+	checks := 0
+	for _, path := range server.Requests() {
+		if path == "GET /api/1.0/license" {
+			checks++
+		}
+	}
+	assert.Assert(t, checks > 1, "the license was only checked %d times", checks)
+}
+
+func TestCreateSensorViaApplianceWithExhaustedLicense(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	appliance := avtest.NewAppliance(server)
+	defer appliance.Close()
+
+	server.AddSensor(avtest.Sensor{Name: "alive"})
+	server.SetLicense(avtest.License{SensorNodeLimit: 1, Expiration: time.Now().Add(time.Hour).Unix()})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// nothing was swept, so there is no point waiting for a slot to be freed
+	err := newApplianceTestClient(server).CreateSensorViaAppliance(ctx, &Sensor{Name: "sensor"}, newApplianceEndpoint(appliance), testPollPolicy)
+	assert.Error(t, err, "the AlienVault license in use does not allow creation of more sensors")
+	assert.Equal(t, 0, len(server.SensorKeys()))
+}
+
+func TestCreateSensorViaExternalActivation(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	// any request to the appliance would fail, as it can't be reached
+	appliance := avtest.NewAppliance(server, avtest.WithFailure(avtest.ApplianceNeverBoots))
+	defer appliance.Close()
+
+	key := server.AddSensorKey()
+
+	// the appliance activates itself some time after terraform starts waiting for it
+	timer := time.AfterFunc(time.Millisecond*100, func() {
+		_ = appliance.Activate(key.ID, "appliance-name", "")
+	})
+	defer timer.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	sensor := &Sensor{Name: "sensor", Description: "activated externally", ActivationCode: key.ID}
+	require.Nil(t, newApplianceTestClient(server).CreateSensorViaExternalActivation(ctx, sensor, testPollPolicy))
+
+	created, ok := server.Sensor(sensor.V1ID)
+	require.True(t, ok)
+	assert.Equal(t, "sensor", created.Name)
+	assert.Equal(t, "activated externally [terraform:unit-test]", created.Description)
+	assert.Equal(t, "Complete", created.SetupStatus)
+	assert.Equal(t, "Ready", created.Status)
+
+	// the key was provided by the caller, so is left for them to clean up
+	assert.Equal(t, 1, len(server.SensorKeys()))
+}
+
+func TestCreateSensorViaExternalActivationWhichNeverHappens(t *testing.T) {
+
+	server := avtest.NewServer()
+	defer server.Close()
+
+	appliance := avtest.NewAppliance(server)
+	defer appliance.Close()
+
+	key := server.AddSensorKey()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+
+	client := newApplianceTestClient(server)
+
+	err := client.CreateSensorViaExternalActivation(ctx, &Sensor{Name: "sensor"}, testPollPolicy)
+	assert.ErrorContains(t, err, "an activation code is required")
+
+	err = client.CreateSensorViaExternalActivation(ctx, &Sensor{Name: "sensor", ActivationCode: key.ID}, testPollPolicy)
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 0, len(server.Sensors()))
+}
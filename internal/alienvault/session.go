@@ -0,0 +1,139 @@
+package alienvault
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// do sends the request, retrying transient failures according to the retry policy. Any request which may have
+// modified data clears the list cache.
+func (client *Client) do(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		// every method which makes changes should already have checked this, but make sure nothing slips through
+		if err := client.checkWritable(req.URL.Path, req.Method); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := client.retry(req, client.doWithSessionRenewal)
+	if req.Method != "GET" && req.Method != "HEAD" {
+		client.cache.invalidate()
+	}
+	return resp, err
+}
+
+// doWithSessionRenewal sends the request, transparently renewing an expired session or bearer token and replaying the
+// request once if the API rejects it as unauthorised.
+func (client *Client) doWithSessionRenewal(req *http.Request) (*http.Response, error) {
+
+	generation := client.currentSessionGeneration()
+
+	resp, err := client.send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+		return resp, nil
+	}
+
+	// we can only replay the request if we are able to rewind the body
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	if req.Header.Get("Authorization") == "" && !client.creds.hasSession() {
+		return resp, nil
+	}
+
+	// the rejected response holds a concurrency slot, which renewing the session or token needs for its own requests
+	resp.Body.Close()
+
+	if req.Header.Get("Authorization") != "" {
+		log.Printf("[DEBUG] bearer token rejected with status %d, requesting a new one...", resp.StatusCode)
+		client.invalidateToken(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+	} else {
+		log.Printf("[DEBUG] session rejected with status %d, logging in again...", resp.StatusCode)
+		if err := client.renewSession(req.Context(), generation); err != nil {
+			return nil, fmt.Errorf("failed to renew expired session: %w", err)
+		}
+	}
+
+	replay, err := client.rebuildRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.send(replay)
+}
+
+// rebuildRequest copies the request with a fresh body and up to date credentials, so that it can be sent again
+func (client *Client) rebuildRequest(req *http.Request) (*http.Request, error) {
+
+	replay := req.Clone(req.Context())
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		replay.Body = body
+	}
+
+	if req.Header.Get("Authorization") != "" {
+		token, err := client.bearerToken(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		replay.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		return replay, nil
+	}
+
+	// the http client adds the session cookies to the original request, so drop them in favour of the renewed ones from the jar
+	replay.Header.Del("Cookie")
+
+	for _, cookie := range client.httpClient.Jar.Cookies(replay.URL) {
+		if cookie.Name == "XSRF-TOKEN" {
+			replay.Header.Set("X-XSRF-TOKEN", cookie.Value)
+		}
+	}
+
+	return replay, nil
+}
+
+func (client *Client) currentSessionGeneration() int {
+	client.sessionLock.Lock()
+	defer client.sessionLock.Unlock()
+	return client.sessionGeneration
+}
+
+// renewSession logs in again, unless another request has already done so since the given session generation was
+// observed. This ensures that parallel requests failing on the same expired session only trigger a single login.
+func (client *Client) renewSession(ctx context.Context, generation int) error {
+
+	client.sessionLock.Lock()
+	defer client.sessionLock.Unlock()
+
+	if client.sessionGeneration != generation {
+		return nil
+	}
+
+	if err := client.login(ctx); err != nil {
+		return err
+	}
+
+	client.sessionGeneration++
+	return nil
+}
+
+// invalidateToken discards the rejected bearer token so that a new one is requested on next use. If the token has
+// already been replaced by another request, the replacement is kept.
+func (client *Client) invalidateToken(rejected string) {
+	client.tokenLock.Lock()
+	defer client.tokenLock.Unlock()
+	if client.token != nil && client.token.AccessToken == rejected {
+		client.token = nil
+	}
+}
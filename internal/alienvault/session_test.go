@@ -0,0 +1,85 @@
+package alienvault
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+// newSessionTestServer returns a server which issues numbered sessions on login, and only accepts the most recent one
+func newSessionTestServer(t *testing.T, logins *int32) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/2.0/users/me":
+			w.Header().Add("Set-Cookie", "XSRF-TOKEN=pre-login")
+		case "/api/1.0/login":
+			n := atomic.AddInt32(logins, 1)
+			w.Header().Add("Set-Cookie", fmt.Sprintf("SESSION=%d", n))
+			w.Header().Add("Set-Cookie", fmt.Sprintf("XSRF-TOKEN=xsrf-%d", n))
+		case "/api/1.0/":
+		case "/api/1.0/license":
+			current := fmt.Sprintf("%d", atomic.LoadInt32(logins))
+			session, err := r.Cookie("SESSION")
+			if err != nil || session.Value != current || r.Header.Get("X-XSRF-TOKEN") != "xsrf-"+current {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"sensorNodesAllowed":2}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestSessionRenewal(t *testing.T) {
+
+	var logins int32
+	ts := newSessionTestServer(t, &logins)
+	defer ts.Close()
+
+	client := newTestClient(t, ts)
+
+	// expire the session server side
+	atomic.AddInt32(&logins, 1)
+
+	license, err := client.GetLicense()
+	require.Nil(t, err)
+	assert.Equal(t, 2, license.SensorNodeLimit)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&logins))
+}
+
+func TestSessionRenewalIsSingleFlight(t *testing.T) {
+
+	var logins int32
+	ts := newSessionTestServer(t, &logins)
+	defer ts.Close()
+
+	client := newTestClient(t, ts)
+
+	atomic.AddInt32(&logins, 1)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetLicense()
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.Nil(t, err)
+	}
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&logins))
+}
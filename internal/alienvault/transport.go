@@ -0,0 +1,199 @@
+package alienvault
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// TLSOptions allow the certificates used when talking to AlienVault and sensor appliances to be customised, e.g. to
+// trust a TLS-intercepting corporate proxy.
+type TLSOptions struct {
+	CACertPEM     []byte // CACertPEM is a PEM bundle of CA certificates to trust in addition to the system roots
+	ClientCertPEM []byte // ClientCertPEM is a PEM encoded client certificate to present, which requires ClientKeyPEM
+	ClientKeyPEM  []byte // ClientKeyPEM is the PEM encoded private key for ClientCertPEM
+}
+
+// WithTLSOptions sets custom CA certificates and/or a client certificate for all connections made by the client
+func WithTLSOptions(options TLSOptions) Option {
+	return func(client *Client) {
+		client.tlsOptions = options
+	}
+}
+
+// WithProxy sends all requests made by the client through the proxy at the given URL, except for hosts matched by the
+// NO_PROXY environment variable. If no proxy URL is given, the standard HTTP(S)_PROXY environment variables are used.
+func WithProxy(proxyURL string) Option {
+	return func(client *Client) {
+		client.proxyURL = proxyURL
+	}
+}
+
+// newTLSConfig builds the TLS configuration used for all connections made by the client
+func (client *Client) newTLSConfig() (*tls.Config, error) {
+
+	config := &tls.Config{
+		InsecureSkipVerify: client.skipTLSVerification || strings.HasPrefix(client.fqdn, "127.0.0.1:"),
+	}
+
+	if len(client.tlsOptions.CACertPEM) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(client.tlsOptions.CACertPEM) {
+			return nil, fmt.Errorf("no valid certificates were found in the supplied CA bundle")
+		}
+		config.RootCAs = pool
+	}
+
+	if len(client.tlsOptions.ClientCertPEM) > 0 || len(client.tlsOptions.ClientKeyPEM) > 0 {
+		cert, err := tls.X509KeyPair(client.tlsOptions.ClientCertPEM, client.tlsOptions.ClientKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// proxyFunc returns the function used by the transport to decide which proxy, if any, a request should go through
+func (client *Client) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+
+	if client.proxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	if _, err := url.Parse(client.proxyURL); err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	config := httpproxy.Config{
+		HTTPProxy:  client.proxyURL,
+		HTTPSProxy: client.proxyURL,
+		NoProxy:    os.Getenv("NO_PROXY"),
+	}
+	if config.NoProxy == "" {
+		config.NoProxy = os.Getenv("no_proxy")
+	}
+
+	proxy := config.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}, nil
+}
+
+// newTransport builds the transport used by both the control node client and the client used to reach sensor appliances
+func (client *Client) newTransport() (*http.Transport, error) {
+
+	tlsConfig, err := client.newTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := client.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Transport{
+		Proxy:           proxy,
+		TLSClientConfig: tlsConfig,
+	}, nil
+}
+
+// ApplianceEndpoint describes how to reach the API of a sensor appliance
+type ApplianceEndpoint struct {
+	IP        net.IP // IP is the address of the appliance, which may be IPv4 or IPv6
+	Scheme    string // Scheme is either http, the default, or https
+	Port      int    // Port is the port the API listens on, or zero for the default port for the scheme
+	CACertPEM []byte // CACertPEM is a PEM bundle of CA certificates to trust for the appliance, in addition to those trusted for AlienVault
+	ProxyURL  string // ProxyURL is a proxy to reach the appliance through, instead of the one used for AlienVault
+
+	Bastion *SSHBastion // Bastion is a host to tunnel requests to the appliance through, if it can't be reached directly
+}
+
+// url returns the URL of the given path on the appliance
+func (appliance ApplianceEndpoint) url(path string) string {
+
+	scheme := appliance.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	host := appliance.IP.String()
+	if appliance.Port != 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(appliance.Port))
+	} else if appliance.IP.To4() == nil {
+		// IPv6 literals must be bracketed, even without a port
+		host = "[" + host + "]"
+	}
+
+	u := url.URL{Scheme: scheme, Host: host, Path: path}
+	return u.String()
+}
+
+// newApplianceClient returns an anonymous client for talking to the given sensor appliance, which shares the TLS and
+// proxy settings used for the control node unless the endpoint overrides them. The returned func must be called once
+// the client is no longer needed, to close any tunnel through a bastion.
+func (client *Client) newApplianceClient(appliance ApplianceEndpoint) (*http.Client, func(), error) {
+
+	transport, err := client.newTransport()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(appliance.CACertPEM) > 0 {
+		pool := transport.TLSClientConfig.RootCAs
+		if pool == nil {
+			pool, err = x509.SystemCertPool()
+			if err != nil || pool == nil {
+				pool = x509.NewCertPool()
+			}
+		} else {
+			pool = pool.Clone()
+		}
+		if !pool.AppendCertsFromPEM(appliance.CACertPEM) {
+			return nil, nil, fmt.Errorf("no valid certificates were found in the appliance CA bundle")
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	if appliance.ProxyURL != "" {
+		proxyURL, err := url.Parse(appliance.ProxyURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid appliance proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	} else if appliance.Bastion != nil {
+		// the proxy used for AlienVault is unlikely to be reachable from the bastion
+		transport.Proxy = nil
+	}
+
+	closeTunnel := func() {}
+	if appliance.Bastion != nil {
+		tunnel, err := newSSHTunnel(appliance.Bastion)
+		if err != nil {
+			return nil, nil, err
+		}
+		transport.DialContext = tunnel.DialContext
+		closeTunnel = func() {
+			_ = tunnel.Close()
+		}
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   time.Second * 5,
+	}, closeTunnel, nil
+}
@@ -0,0 +1,191 @@
+package alienvault
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+func generateTestCertificate(t *testing.T) (certPEM []byte, keyPEM []byte) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "terraform"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.Nil(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestTLSOptionsCACert(t *testing.T) {
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	client := New("example.com", Credentials{}, false, 1, WithTLSOptions(TLSOptions{
+		CACertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}),
+	}))
+
+	config, err := client.newTLSConfig()
+	require.Nil(t, err)
+	assert.Assert(t, !config.InsecureSkipVerify)
+	require.NotNil(t, config.RootCAs)
+
+	_, err = ts.Certificate().Verify(x509.VerifyOptions{Roots: config.RootCAs, DNSName: "example.com"})
+	require.Nil(t, err)
+}
+
+func TestTLSOptionsInvalidCACert(t *testing.T) {
+	client := New("example.com", Credentials{}, false, 1, WithTLSOptions(TLSOptions{
+		CACertPEM: []byte("not a certificate"),
+	}))
+	_, err := client.newTLSConfig()
+	require.NotNil(t, err)
+}
+
+func TestTLSOptionsClientCert(t *testing.T) {
+
+	certPEM, keyPEM := generateTestCertificate(t)
+
+	client := New("example.com", Credentials{}, false, 1, WithTLSOptions(TLSOptions{
+		ClientCertPEM: certPEM,
+		ClientKeyPEM:  keyPEM,
+	}))
+
+	config, err := client.newTLSConfig()
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(config.Certificates))
+
+	client = New("example.com", Credentials{}, false, 1, WithTLSOptions(TLSOptions{
+		ClientCertPEM: certPEM,
+	}))
+	_, err = client.newTLSConfig()
+	require.NotNil(t, err)
+}
+
+func TestProxyRespectsNoProxy(t *testing.T) {
+
+	original := os.Getenv("NO_PROXY")
+	defer os.Setenv("NO_PROXY", original)
+	require.Nil(t, os.Setenv("NO_PROXY", "internal.example.com"))
+
+	client := New("example.alienvault.cloud", Credentials{}, false, 1, WithProxy("http://proxy.example.com:3128"))
+
+	proxy, err := client.proxyFunc()
+	require.Nil(t, err)
+
+	proxied, err := proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "example.alienvault.cloud"}})
+	require.Nil(t, err)
+	require.NotNil(t, proxied)
+	assert.Equal(t, "proxy.example.com:3128", proxied.Host)
+
+	direct, err := proxy(&http.Request{URL: &url.URL{Scheme: "http", Host: "internal.example.com"}})
+	require.Nil(t, err)
+	assert.Assert(t, direct == nil)
+}
+
+func TestApplianceClientSharesTransportSettings(t *testing.T) {
+
+	client := New("example.alienvault.cloud", Credentials{}, true, 1, WithProxy("http://proxy.example.com:3128"))
+
+	applianceClient, _, err := client.newApplianceClient(ApplianceEndpoint{})
+	require.Nil(t, err)
+
+	transport := applianceClient.Transport.(*http.Transport)
+	assert.Assert(t, transport.TLSClientConfig.InsecureSkipVerify)
+
+	proxied, err := transport.Proxy(&http.Request{URL: &url.URL{Scheme: "http", Host: "10.0.0.1"}})
+	require.Nil(t, err)
+	require.NotNil(t, proxied)
+}
+
+func TestApplianceEndpointURL(t *testing.T) {
+
+	var tests = []struct {
+		endpoint ApplianceEndpoint
+		url      string
+	}{
+		{ApplianceEndpoint{IP: net.ParseIP("10.0.0.1")}, "http://10.0.0.1/api/1.0/status"},
+		{ApplianceEndpoint{IP: net.ParseIP("10.0.0.1"), Scheme: "https", Port: 8443}, "https://10.0.0.1:8443/api/1.0/status"},
+		{ApplianceEndpoint{IP: net.ParseIP("2001:db8::1")}, "http://[2001:db8::1]/api/1.0/status"},
+		{ApplianceEndpoint{IP: net.ParseIP("2001:db8::1"), Scheme: "https", Port: 443}, "https://[2001:db8::1]:443/api/1.0/status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			assert.Equal(t, tt.url, tt.endpoint.url("/api/1.0/status"))
+		})
+	}
+}
+
+func TestApplianceClientOverridesTransportSettings(t *testing.T) {
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	port, err := strconv.Atoi(ts.URL[strings.LastIndex(ts.URL, ":")+1:])
+	require.Nil(t, err)
+
+	client := New("example.alienvault.cloud", Credentials{}, false, 1, WithProxy("http://proxy.example.com:3128"))
+
+	endpoint := ApplianceEndpoint{IP: net.ParseIP("127.0.0.1"), Scheme: "https", Port: port}
+
+	// the appliance's certificate is only trusted once its CA is
+	untrusting, _, err := client.newApplianceClient(endpoint)
+	require.Nil(t, err)
+	untrusting.Transport.(*http.Transport).Proxy = nil
+	_, err = untrusting.Get(endpoint.url("/api/1.0/status"))
+	assert.ErrorContains(t, err, "certificate")
+
+	endpoint.CACertPEM = caPEM
+	trusting, _, err := client.newApplianceClient(endpoint)
+	require.Nil(t, err)
+	trusting.Transport.(*http.Transport).Proxy = nil
+	resp, err := trusting.Get(endpoint.url("/api/1.0/status"))
+	require.Nil(t, err)
+	resp.Body.Close()
+
+	// the appliance proxy replaces the one used for AlienVault, even for hosts matched by NO_PROXY
+	os.Setenv("NO_PROXY", "10.0.0.1")
+	defer os.Unsetenv("NO_PROXY")
+
+	endpoint.ProxyURL = "http://appliance-proxy.example.com:8080"
+	proxying, _, err := client.newApplianceClient(endpoint)
+	require.Nil(t, err)
+
+	proxied, err := proxying.Transport.(*http.Transport).Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "10.0.0.1"}})
+	require.Nil(t, err)
+	require.NotNil(t, proxied)
+	assert.Equal(t, "appliance-proxy.example.com:8080", proxied.Host)
+
+	_, _, err = client.newApplianceClient(ApplianceEndpoint{CACertPEM: []byte("not a certificate")})
+	assert.ErrorContains(t, err, "appliance CA bundle")
+}
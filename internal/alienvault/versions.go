@@ -0,0 +1,66 @@
+package alienvault
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+// internalAPIVersion is the only version of the API which serves jobs, sensor keys and the license. These have no
+// equivalent in the public v2 API.
+const internalAPIVersion = 1
+
+// negotiateSensorAPIVersion decides which API version to use for sensor endpoints. Client credentials can only be used
+// with the v2 API. With a cookie session, the v1 sensor endpoints are preferred as they return both the v1 and v2 ID of
+// each sensor, but newer control nodes which no longer serve them fall back to v2.
+func (client *Client) negotiateSensorAPIVersion(ctx context.Context) error {
+
+	switch {
+	case client.version == 1:
+		// the user has told us v1 is available, so there's no need to check, but it still needs a session
+		client.v1SensorsAvailable = client.creds.hasSession()
+	case client.creds.hasSession():
+		supported, err := client.probeV1Sensors(ctx)
+		if err != nil {
+			return err
+		}
+		client.v1SensorsAvailable = supported
+	}
+
+	switch {
+	case client.version != 0:
+		client.sensorAPIVersion = client.version
+	case client.creds.hasClientCredentials() || !client.v1SensorsAvailable:
+		client.sensorAPIVersion = 2
+	default:
+		client.sensorAPIVersion = 1
+	}
+
+	log.Printf("[DEBUG] using v%d API for sensors", client.sensorAPIVersion)
+	return nil
+}
+
+// probeV1Sensors returns true if the control node still serves the v1 sensor endpoints
+func (client *Client) probeV1Sensors(ctx context.Context) (bool, error) {
+
+	req, err := client.createSessionRequest(ctx, internalAPIVersion, "GET", "/sensors", nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := client.retry(req, client.send)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone:
+		return false, nil
+	}
+
+	if err := checkResponse(resp); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,122 @@
+package alienvault
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+// newVersionTestServer returns a server which serves jobs from the v1 API and sensors from the v2 API, and from the
+// v1 API only if v1Sensors is set. All requested paths are recorded.
+func newVersionTestServer(t *testing.T, v1Sensors bool, paths *[]string) *httptest.Server {
+	var lock sync.Mutex
+	return newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		*paths = append(*paths, r.Method+" "+r.URL.Path)
+		lock.Unlock()
+
+		switch r.URL.Path {
+		case "/api/1.0/scheduler":
+			fmt.Fprint(w, `[]`)
+		case "/api/1.0/sensors":
+			if !v1Sensors {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, `[{"uuid":"v1-abc","id":"v2-abc","name":"sensor-1"}]`)
+		case "/api/2.0/sensors":
+			fmt.Fprint(w, `{"_embedded":{"sensors":[{"id":"v2-abc","name":"sensor-1"}]}}`)
+		case "/api/1.0/sensors/v1-abc", "/api/2.0/sensors/v2-abc":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func newVersionTestClient(t *testing.T, ts *httptest.Server, version int) *Client {
+	client := New(testFQDN(ts), testCredentials, true, version)
+	require.Nil(t, client.Authenticate())
+	return client
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNegotiatesV1SensorsWhenAvailable(t *testing.T) {
+
+	var paths []string
+	ts := newVersionTestServer(t, true, &paths)
+	defer ts.Close()
+
+	client := newVersionTestClient(t, ts, 0)
+	assert.Equal(t, 1, client.publicAPIVersion())
+
+	sensors, err := client.GetSensors()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(sensors))
+	assert.Equal(t, "v1-abc", sensors[0].ID())
+}
+
+func TestNegotiatesV2SensorsWhenV1Unavailable(t *testing.T) {
+
+	var paths []string
+	ts := newVersionTestServer(t, false, &paths)
+	defer ts.Close()
+
+	client := newVersionTestClient(t, ts, 0)
+	assert.Equal(t, 2, client.publicAPIVersion())
+
+	sensors, err := client.GetSensors()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(sensors))
+	assert.Equal(t, "v2-abc", sensors[0].ID())
+
+	// jobs are only served by the v1 API, whatever version is used for sensors
+	_, err = client.GetAWSBucketJobs()
+	require.Nil(t, err)
+	assert.Assert(t, containsPath(paths, "GET /api/1.0/scheduler"))
+}
+
+func TestForcedV2StillUsesV1ForJobs(t *testing.T) {
+
+	var paths []string
+	ts := newVersionTestServer(t, true, &paths)
+	defer ts.Close()
+
+	client := newVersionTestClient(t, ts, 2)
+
+	_, err := client.GetAWSBucketJobs()
+	require.Nil(t, err)
+	assert.Assert(t, containsPath(paths, "GET /api/1.0/scheduler"))
+	assert.Assert(t, !containsPath(paths, "GET /api/2.0/scheduler"))
+}
+
+func TestV2SensorsAreMappedToV1IDs(t *testing.T) {
+
+	var paths []string
+	ts := newVersionTestServer(t, true, &paths)
+	defer ts.Close()
+
+	client := newVersionTestClient(t, ts, 2)
+
+	// sensors listed by the v2 API can still be found by the v1 ID which jobs refer to
+	sensor, err := client.GetSensor("v1-abc")
+	require.Nil(t, err)
+	assert.Equal(t, "v1-abc", sensor.V1ID)
+	assert.Equal(t, "v2-abc", sensor.V2ID)
+
+	// and are addressed by their v2 ID, even if the caller only knows the v1 ID
+	require.Nil(t, client.DeleteSensor(&Sensor{V1ID: "v1-abc", V2ID: "v1-abc"}))
+	assert.Assert(t, containsPath(paths, "DELETE /api/2.0/sensors/v2-abc"))
+}
@@ -0,0 +1,293 @@
+package avtest
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// applianceVersion is the software version reported by emulated appliances
+const applianceVersion = "6.0.0"
+
+// ApplianceFailure is a way in which an emulated appliance can fail to bring up a sensor
+type ApplianceFailure int
+
+const (
+	// ApplianceHealthy goes through the whole activation workflow without failing
+	ApplianceHealthy ApplianceFailure = iota
+	// ApplianceNeverBoots drops every connection, as if the appliance never came up
+	ApplianceNeverBoots
+	// ApplianceWrongStatus reports a status other than notConnected once it has booted
+	ApplianceWrongStatus
+	// ApplianceRejectsActivation rejects every activation request
+	ApplianceRejectsActivation
+	// ApplianceNeverReady registers the sensor, but it never becomes Ready once its setup is completed
+	ApplianceNeverReady
+)
+
+// ApplianceOption configures optional behaviour of an emulated appliance
+type ApplianceOption func(*Appliance)
+
+// WithTLS serves the appliance API over HTTPS, with a certificate issued by the CA returned by CACertPEM
+func WithTLS() ApplianceOption {
+	return func(a *Appliance) {
+		a.tls = true
+	}
+}
+
+// WithBootDelay drops connections to the appliance until the given time has passed since it was created
+func WithBootDelay(delay time.Duration) ApplianceOption {
+	return func(a *Appliance) {
+		a.bootDelay = delay
+	}
+}
+
+// WithRegistrationDelay delays the registration of the sensor with the control node after the appliance is activated
+func WithRegistrationDelay(delay time.Duration) ApplianceOption {
+	return func(a *Appliance) {
+		a.registrationDelay = delay
+	}
+}
+
+// WithReadyDelay delays the sensor becoming Ready after its setup is completed
+func WithReadyDelay(delay time.Duration) ApplianceOption {
+	return func(a *Appliance) {
+		a.readyDelay = delay
+	}
+}
+
+// WithFailure makes the appliance fail in the given way
+func WithFailure(failure ApplianceFailure) ApplianceOption {
+	return func(a *Appliance) {
+		a.failure = failure
+	}
+}
+
+// Appliance is an HTTP server emulating a sensor appliance, which registers a sensor with the given fake control node
+// when activated. It listens on a loopback address, given by Listener.Addr.
+type Appliance struct {
+	*httptest.Server
+
+	control *Server
+	created time.Time
+	tls     bool
+
+	bootDelay         time.Duration
+	registrationDelay time.Duration
+	readyDelay        time.Duration
+	failure           ApplianceFailure
+
+	lock      sync.Mutex
+	activated bool
+	sensorID  string
+	timers    []*time.Timer
+	closed    bool
+}
+
+// NewAppliance starts a new emulated appliance which registers its sensor with the given server. It should be closed by
+// the caller when no longer needed.
+func NewAppliance(control *Server, options ...ApplianceOption) *Appliance {
+	a := &Appliance{
+		control: control,
+		created: time.Now(),
+	}
+	for _, option := range options {
+		option(a)
+	}
+	a.Server = httptest.NewUnstartedServer(http.HandlerFunc(a.serveHTTP))
+	a.Server.Listener = &bootingListener{Listener: a.Server.Listener, appliance: a}
+	if a.tls {
+		a.Server.StartTLS()
+	} else {
+		a.Server.Start()
+	}
+	return a
+}
+
+// Scheme returns the scheme the appliance API is served over
+func (a *Appliance) Scheme() string {
+	if a.tls {
+		return "https"
+	}
+	return "http"
+}
+
+// CACertPEM returns the PEM encoded certificate of the CA which issued the appliance's certificate, or nil if it is not
+// served over HTTPS
+func (a *Appliance) CACertPEM() []byte {
+	if !a.tls {
+		return nil
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: a.Certificate().Raw})
+}
+
+// SensorID returns the v1 ID of the sensor registered by the appliance, if it has registered one yet
+func (a *Appliance) SensorID() (string, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.sensorID, a.sensorID != ""
+}
+
+// Close stops any pending state transitions and shuts down the appliance
+func (a *Appliance) Close() {
+	a.lock.Lock()
+	a.closed = true
+	for _, timer := range a.timers {
+		timer.Stop()
+	}
+	a.lock.Unlock()
+
+	a.Server.Close()
+}
+
+func (a *Appliance) booted() bool {
+	return a.failure != ApplianceNeverBoots && time.Since(a.created) >= a.bootDelay
+}
+
+// bootingListener closes every connection it accepts until the appliance has booted, as if nothing were listening
+type bootingListener struct {
+	net.Listener
+	appliance *Appliance
+}
+
+func (l *bootingListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil || l.appliance.booted() {
+			return conn, err
+		}
+		conn.Close()
+	}
+}
+
+// after calls f once the delay has passed, unless the appliance is closed first
+func (a *Appliance) after(delay time.Duration, f func()) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.closed {
+		return
+	}
+	a.timers = append(a.timers, time.AfterFunc(delay, f))
+}
+
+func (a *Appliance) serveHTTP(w http.ResponseWriter, r *http.Request) {
+
+	switch {
+	case r.URL.Path == "/api/1.0/status" && r.Method == "GET":
+		a.handleStatus(w, r)
+	case r.URL.Path == "/api/1.0/connect" && r.Method == "POST":
+		a.handleConnect(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *Appliance) handleStatus(w http.ResponseWriter, r *http.Request) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	status := "notConnected"
+	switch {
+	case a.failure == ApplianceWrongStatus:
+		status = "upgrading"
+	case a.activated:
+		status = "connected"
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": status})
+}
+
+func (a *Appliance) handleConnect(w http.ResponseWriter, r *http.Request) {
+
+	activation := applianceActivation{}
+	if err := json.NewDecoder(r.Body).Decode(&activation); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if statusCode, err := a.activate(activation); err != nil {
+		http.Error(w, err.Error(), statusCode)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "connecting"})
+}
+
+// applianceActivation is the request used to activate an appliance
+type applianceActivation struct {
+	Key         string `json:"key"`
+	MasterNode  string `json:"masterNode"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Activate activates the appliance with the given key, without any request being made to it, as an appliance does when
+// given a key in its user data. The sensor is registered with the name and description given.
+func (a *Appliance) Activate(key string, name string, description string) error {
+	_, err := a.activate(applianceActivation{
+		Key:         key,
+		MasterNode:  a.control.FQDN(),
+		Name:        name,
+		Description: description,
+	})
+	return err
+}
+
+// activate checks the activation is valid, and if so registers the sensor once the registration delay has passed. The
+// status code to respond with is returned along with any error.
+func (a *Appliance) activate(activation applianceActivation) (int, error) {
+
+	if a.failure == ApplianceRejectsActivation {
+		return http.StatusBadRequest, fmt.Errorf("activation rejected")
+	}
+	if activation.MasterNode != a.control.FQDN() {
+		return http.StatusBadRequest, fmt.Errorf("unknown control node %s", activation.MasterNode)
+	}
+	if err := a.control.checkSensorKey(activation.Key); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	a.lock.Lock()
+	if a.activated {
+		a.lock.Unlock()
+		return http.StatusConflict, fmt.Errorf("appliance is already connected")
+	}
+	a.activated = true
+	a.lock.Unlock()
+
+	a.after(a.registrationDelay, func() {
+		sensor := a.control.registerSensor(activation.Key, Sensor{
+			Name:            activation.Name,
+			Description:     activation.Description,
+			Status:          "Connecting",
+			SetupStatus:     "Pending",
+			Type:            "Virtual",
+			Version:         applianceVersion,
+			LastConnected:   time.Now().Unix(),
+			onSetupComplete: a.setupComplete,
+		})
+
+		a.lock.Lock()
+		a.sensorID = sensor.V1ID
+		a.lock.Unlock()
+	})
+
+	return http.StatusOK, nil
+}
+
+// setupComplete is called by the control node once the setup of the sensor is completed
+func (a *Appliance) setupComplete(id string) {
+	if a.failure == ApplianceNeverReady {
+		return
+	}
+	a.after(a.readyDelay, func() {
+		a.control.SetSensorStatus(id, "Ready")
+	})
+}
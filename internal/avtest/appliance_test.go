@@ -0,0 +1,85 @@
+package avtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func activate(t *testing.T, appliance *Appliance, key string, masterNode string) int {
+	body, err := json.Marshal(map[string]string{"key": key, "masterNode": masterNode, "name": "sensor"})
+	require.Nil(t, err)
+	resp, err := appliance.Client().Post(appliance.URL+"/api/1.0/connect", "application/json", bytes.NewReader(body))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestApplianceActivation(t *testing.T) {
+
+	server := NewServer()
+	defer server.Close()
+
+	appliance := NewAppliance(server, WithTLS())
+	defer appliance.Close()
+
+	key := server.AddSensorKey()
+
+	// activation requires the control node's address and a key it issued
+	assert.Equal(t, http.StatusBadRequest, activate(t, appliance, key.ID, "elsewhere.alienvault.cloud"))
+	assert.Equal(t, http.StatusBadRequest, activate(t, appliance, "unknown", server.FQDN()))
+	assert.Equal(t, http.StatusOK, activate(t, appliance, key.ID, server.FQDN()))
+
+	require.Eventually(t, func() bool {
+		_, ok := appliance.SensorID()
+		return ok
+	}, time.Second, time.Millisecond*10)
+
+	// the key is consumed by the registered sensor, so can't be used again
+	id, _ := appliance.SensorID()
+	keys := server.SensorKeys()
+	require.Equal(t, 1, len(keys))
+	require.NotNil(t, keys[0].NodeID)
+	assert.Equal(t, id, *keys[0].NodeID)
+	assert.Equal(t, http.StatusBadRequest, activate(t, appliance, key.ID, server.FQDN()))
+
+	sensor, ok := server.Sensor(id)
+	require.True(t, ok)
+	assert.Equal(t, "Connecting", sensor.Status)
+}
+
+func TestApplianceBootDelay(t *testing.T) {
+
+	server := NewServer()
+	defer server.Close()
+
+	appliance := NewAppliance(server, WithBootDelay(time.Millisecond*100))
+	defer appliance.Close()
+
+	_, err := appliance.Client().Get(appliance.URL + "/api/1.0/status")
+	assert.NotNil(t, err)
+
+	time.Sleep(time.Millisecond * 100)
+
+	resp, err := appliance.Client().Get(appliance.URL + "/api/1.0/status")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestApplianceNeverBoots(t *testing.T) {
+
+	server := NewServer()
+	defer server.Close()
+
+	appliance := NewAppliance(server, WithFailure(ApplianceNeverBoots))
+	defer appliance.Close()
+
+	_, err := appliance.Client().Get(appliance.URL + "/api/1.0/status")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,186 @@
+package avtest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// BastionUser is the user accepted by the fake bastion
+const BastionUser = "terraform"
+
+// Bastion is an SSH server which forwards connections to any address, like a bastion host. It accepts BastionUser
+// authenticating with any of the public keys it is given.
+type Bastion struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	hostKey  ssh.Signer
+
+	lock      sync.Mutex
+	forwarded []string
+	conns     []net.Conn
+	wg        sync.WaitGroup
+}
+
+// NewBastion starts a new fake bastion which accepts the given keys. It should be closed by the caller when no longer
+// needed.
+func NewBastion(authorizedKeys ...ssh.PublicKey) *Bastion {
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("avtest: failed to generate bastion host key: %v", err))
+	}
+	hostKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		panic(fmt.Sprintf("avtest: failed to create bastion host key: %v", err))
+	}
+
+	b := &Bastion{hostKey: hostKey}
+	b.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() != BastionUser {
+				return nil, fmt.Errorf("unknown user %s", conn.User())
+			}
+			for _, authorized := range authorizedKeys {
+				if string(authorized.Marshal()) == string(key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown key for %s", conn.User())
+		},
+	}
+	b.config.AddHostKey(hostKey)
+
+	b.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("avtest: failed to listen on a port: %v", err))
+	}
+
+	b.wg.Add(1)
+	go b.serve()
+	return b
+}
+
+// Host returns the host and port the bastion listens on
+func (b *Bastion) Host() (string, int) {
+	addr := b.listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// HostKey returns the public key of the bastion in authorized_keys format
+func (b *Bastion) HostKey() string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(b.hostKey.PublicKey())))
+}
+
+// Forwarded returns the addresses of every connection forwarded by the bastion so far
+func (b *Bastion) Forwarded() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return append([]string{}, b.forwarded...)
+}
+
+// Close stops the bastion and closes any open connections
+func (b *Bastion) Close() {
+	b.listener.Close()
+
+	b.lock.Lock()
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.lock.Unlock()
+
+	b.wg.Wait()
+}
+
+// track records a connection, so it can be closed along with the bastion
+func (b *Bastion) track(conn net.Conn) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.conns = append(b.conns, conn)
+}
+
+func (b *Bastion) serve() {
+	defer b.wg.Done()
+
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.track(conn)
+
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.handle(conn)
+		}()
+	}
+}
+
+func (b *Bastion) handle(conn net.Conn) {
+
+	serverConn, chans, reqs, err := ssh.NewServerConn(conn, b.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer serverConn.Close()
+
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only port forwarding is supported")
+			continue
+		}
+
+		forward := struct {
+			DestAddr string
+			DestPort uint32
+			OrigAddr string
+			OrigPort uint32
+		}{}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &forward); err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+
+		address := net.JoinHostPort(forward.DestAddr, strconv.Itoa(int(forward.DestPort)))
+
+		b.lock.Lock()
+		b.forwarded = append(b.forwarded, address)
+		b.lock.Unlock()
+
+		target, err := net.Dial("tcp", address)
+		if err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		b.track(target)
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			target.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+
+		go func() {
+			defer channel.Close()
+			_, _ = io.Copy(channel, target)
+		}()
+		go func() {
+			defer target.Close()
+			_, _ = io.Copy(target, channel)
+		}()
+	}
+}
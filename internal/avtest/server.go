@@ -0,0 +1,445 @@
+// Package avtest provides an in-process fake of the USM Anywhere API, so that the client and provider can be tested
+// without a live tenant.
+package avtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The credentials accepted by the fake server
+const (
+	Username     = "terraform@example.com"
+	Password     = "password"
+	ClientID     = "client-id"
+	ClientSecret = "client-secret"
+)
+
+// Hook is called before the server handles each request. If it returns true, it is assumed to have written the
+// response itself and the request is not handled any further.
+type Hook func(w http.ResponseWriter, r *http.Request) bool
+
+// Server is a TLS server emulating the parts of the USM Anywhere API used by this provider, backed by an in-memory
+// store. It handles the login flow, including the XSRF cookie, as well as client credentials for the v2 API.
+type Server struct {
+	*httptest.Server
+
+	lock    sync.Mutex
+	ids     int
+	latency time.Duration
+	hooks   []Hook
+	history []string
+
+	v1Sensors bool
+	sessions  map[string]string // session ID to current XSRF token
+	tokens    map[string]bool
+
+	sensors []*Sensor
+	keys    []*SensorKey
+	jobs    []Job
+	license License
+}
+
+// Option configures optional behaviour of the server
+type Option func(*Server)
+
+// WithoutV1Sensors makes the server behave like newer control nodes, which only serve sensors via the v2 API
+func WithoutV1Sensors() Option {
+	return func(s *Server) {
+		s.v1Sensors = false
+	}
+}
+
+// NewServer starts a new fake server. It should be closed by the caller when no longer needed.
+func NewServer(options ...Option) *Server {
+	s := &Server{
+		v1Sensors: true,
+		sessions:  map[string]string{},
+		tokens:    map[string]bool{},
+		sensors:   []*Sensor{},
+		keys:      []*SensorKey{},
+		jobs:      []Job{},
+		license:   defaultLicense(),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// FQDN returns the address of the server, in the form expected by the client and provider
+func (s *Server) FQDN() string {
+	return strings.TrimPrefix(s.URL, "https://")
+}
+
+// AddHook adds a hook which is called before each request is handled, in the order hooks were added
+func (s *Server) AddHook(hook Hook) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.hooks = append(s.hooks, hook)
+}
+
+// Fail responds to the next times requests matching the method and path with the given status code. An empty method
+// matches any method.
+func (s *Server) Fail(method string, path string, statusCode int, times int) {
+	var lock sync.Mutex
+	s.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if (method != "" && r.Method != method) || r.URL.Path != path {
+			return false
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		if times <= 0 {
+			return false
+		}
+		times--
+		http.Error(w, fmt.Sprintf("injected failure for %s %s", r.Method, r.URL.Path), statusCode)
+		return true
+	})
+}
+
+// SetLatency delays every response by the given duration
+func (s *Server) SetLatency(latency time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.latency = latency
+}
+
+// ExpireSessions invalidates every session and bearer token, so clients have to authenticate again
+func (s *Server) ExpireSessions() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.sessions = map[string]string{}
+	s.tokens = map[string]bool{}
+}
+
+// Requests returns every request received so far, as "METHOD /path"
+func (s *Server) Requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]string{}, s.history...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+
+	s.lock.Lock()
+	s.history = append(s.history, r.Method+" "+r.URL.Path)
+	latency := s.latency
+	hooks := append([]Hook{}, s.hooks...)
+	s.lock.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	for _, hook := range hooks {
+		if hook(w, r) {
+			return
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch r.URL.Path {
+	case "/api/2.0/users/me":
+		s.handleUsersMe(w, r)
+		return
+	case "/api/1.0/login":
+		s.handleLogin(w, r)
+		return
+	case "/api/2.0/oauth/token":
+		s.handleToken(w, r)
+		return
+	}
+
+	version, path := splitVersion(r.URL.Path)
+	if version == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !s.authorised(r, version) {
+		http.Error(w, "unauthorised", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case path == "/" && version == "1.0":
+		s.rotateXSRF(w, r)
+	case path == "/license" && version == "1.0":
+		s.handleLicense(w, r)
+	case path == "/sensors/key" || strings.HasPrefix(path, "/sensors/key/"):
+		if version != "1.0" {
+			http.NotFound(w, r)
+			return
+		}
+		s.handleSensorKeys(w, r, strings.TrimPrefix(strings.TrimPrefix(path, "/sensors/key"), "/"))
+	case path == "/sensors" || strings.HasPrefix(path, "/sensors/"):
+		if version == "1.0" && !s.v1Sensors {
+			http.NotFound(w, r)
+			return
+		}
+		s.handleSensors(w, r, version, strings.TrimPrefix(strings.TrimPrefix(path, "/sensors"), "/"))
+	case (path == "/scheduler" || strings.HasPrefix(path, "/scheduler/")) && version == "1.0":
+		s.handleScheduler(w, r, strings.TrimPrefix(strings.TrimPrefix(path, "/scheduler"), "/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// splitVersion splits a path such as /api/1.0/sensors into the API version and the remaining path
+func splitVersion(path string) (string, string) {
+	for _, version := range []string{"1.0", "2.0"} {
+		prefix := "/api/" + version
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			rest := strings.TrimPrefix(path, prefix)
+			if rest == "" {
+				rest = "/"
+			}
+			return version, rest
+		}
+	}
+	return "", ""
+}
+
+// authorised checks the request carries a valid bearer token (v2 only), or a valid session cookie and, for requests
+// which make changes, a matching XSRF token
+func (s *Server) authorised(r *http.Request, version string) bool {
+
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		return version == "2.0" && s.tokens[strings.TrimPrefix(auth, "Bearer ")]
+	}
+
+	session, err := r.Cookie("SESSION")
+	if err != nil {
+		return false
+	}
+	xsrf, ok := s.sessions[session.Value]
+	if !ok {
+		return false
+	}
+	if r.Method == "GET" || r.Method == "HEAD" {
+		return true
+	}
+	return r.Header.Get("X-XSRF-TOKEN") == xsrf
+}
+
+func (s *Server) handleUsersMe(w http.ResponseWriter, r *http.Request) {
+	// before login this just hands out an XSRF token, which must be sent back with the login request
+	http.SetCookie(w, &http.Cookie{Name: "XSRF-TOKEN", Value: s.nextID("xsrf"), Path: "/"})
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	xsrf, err := r.Cookie("XSRF-TOKEN")
+	if err != nil || xsrf.Value == "" || r.Header.Get("X-XSRF-TOKEN") != xsrf.Value {
+		http.Error(w, "invalid XSRF token", http.StatusForbidden)
+		return
+	}
+
+	creds := struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if creds.Email != Username || creds.Password != Password {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	session := s.nextID("session")
+	s.sessions[session] = s.nextID("xsrf")
+	http.SetCookie(w, &http.Cookie{Name: "SESSION", Value: session, Path: "/"})
+	http.SetCookie(w, &http.Cookie{Name: "XSRF-TOKEN", Value: s.sessions[session], Path: "/"})
+}
+
+func (s *Server) rotateXSRF(w http.ResponseWriter, r *http.Request) {
+	session, err := r.Cookie("SESSION")
+	if err != nil {
+		return
+	}
+	s.sessions[session.Value] = s.nextID("xsrf")
+	http.SetCookie(w, &http.Cookie{Name: "XSRF-TOKEN", Value: s.sessions[session.Value], Path: "/"})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+
+	id, secret, ok := r.BasicAuth()
+	if r.Method != "POST" || !ok || id != ClientID || secret != ClientSecret || r.URL.Query().Get("grant_type") != "client_credentials" {
+		http.Error(w, "invalid client", http.StatusUnauthorized)
+		return
+	}
+
+	token := s.nextID("token")
+	s.tokens[token] = true
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"token_type":   "bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (s *Server) handleLicense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.license)
+}
+
+func (s *Server) handleSensors(w http.ResponseWriter, r *http.Request, version string, id string) {
+
+	switch {
+	case id == "" && r.Method == "GET":
+		if version == "1.0" {
+			writeJSON(w, http.StatusOK, s.sensors)
+			return
+		}
+		// the v2 API only knows sensors by their v2 ID
+		sensors := []map[string]interface{}{}
+		for _, sensor := range s.sensors {
+			sensors = append(sensors, map[string]interface{}{
+				"id":            sensor.V2ID,
+				"name":          sensor.Name,
+				"description":   sensor.Description,
+				"status":        sensor.Status,
+				"setupStatus":   sensor.SetupStatus,
+				"type":          sensor.Type,
+				"version":       sensor.Version,
+				"lastConnected": sensor.LastConnected,
+			})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"_embedded": map[string]interface{}{"sensors": sensors},
+		})
+
+	case id != "" && (r.Method == "PATCH" || r.Method == "DELETE"):
+		sensor := s.findSensor(id)
+		if sensor == nil || (version == "1.0" && id != sensor.V1ID) || (version == "2.0" && id != sensor.V2ID) {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method == "DELETE" {
+			s.deleteSensor(id)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		patch := struct {
+			Name        *string `json:"name"`
+			Description *string `json:"description"`
+			SetupStatus *string `json:"setupStatus"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if patch.Name != nil {
+			sensor.Name = *patch.Name
+		}
+		if patch.Description != nil {
+			sensor.Description = *patch.Description
+		}
+		if patch.SetupStatus != nil {
+			sensor.SetupStatus = *patch.SetupStatus
+			if sensor.SetupStatus == "Complete" && sensor.onSetupComplete != nil {
+				go sensor.onSetupComplete(sensor.V1ID)
+				sensor.onSetupComplete = nil
+			}
+		}
+		writeJSON(w, http.StatusOK, sensor)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleSensorKeys(w http.ResponseWriter, r *http.Request, id string) {
+
+	switch {
+	case id == "" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, s.keys)
+	case id == "" && r.Method == "POST":
+		if len(s.sensors)+s.unusedSensorKeys() >= s.license.SensorNodeLimit {
+			http.Error(w, "license exhausted", http.StatusConflict)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.createSensorKey())
+	case id != "" && r.Method == "DELETE":
+		if !s.deleteSensorKey(id) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleScheduler(w http.ResponseWriter, r *http.Request, uuid string) {
+
+	switch {
+	case uuid == "" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, s.jobs)
+	case uuid == "" && r.Method == "POST":
+		job := Job{}
+		if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		delete(job, "uuid")
+		s.storeJob(job)
+		writeJSON(w, http.StatusOK, job)
+	case uuid != "" && r.Method == "PUT":
+		job := Job{}
+		if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !s.replaceJob(uuid, job) {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	case uuid != "" && r.Method == "DELETE":
+		if !s.deleteJob(uuid) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,118 @@
+package avtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/terraform-provider-alienvault/internal/alienvault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(server *Server, creds alienvault.Credentials) *alienvault.Client {
+	return alienvault.New(server.FQDN(), creds, true, 0, alienvault.WithRetryPolicy(alienvault.RetryPolicy{
+		MaxRetries: 1,
+		MinWait:    time.Millisecond,
+		MaxWait:    time.Millisecond,
+	}))
+}
+
+func TestServerSessionFlow(t *testing.T) {
+
+	server := NewServer()
+	defer server.Close()
+
+	client := newTestClient(server, alienvault.Credentials{Username: Username, Password: Password})
+
+	job := &alienvault.AWSBucketJob{}
+	job.Name = "job"
+	job.Params.BucketName = "bucket"
+	require.Nil(t, client.CreateAWSBucketJob(job))
+	require.NotEmpty(t, job.UUID)
+
+	stored, ok := server.Job(job.UUID)
+	require.True(t, ok)
+	assert.Equal(t, "bucket", stored["params"].(map[string]interface{})["bucketName"])
+
+	// the session is renewed transparently once it expires
+	server.ExpireSessions()
+
+	fetched, err := client.GetAWSBucketJob(job.UUID)
+	require.Nil(t, err)
+	assert.Equal(t, "job", fetched.Name)
+
+	require.Nil(t, client.DeleteAWSBucketJob(job))
+	assert.Empty(t, server.Jobs())
+}
+
+func TestServerRejectsBadCredentials(t *testing.T) {
+
+	server := NewServer()
+	defer server.Close()
+
+	client := newTestClient(server, alienvault.Credentials{Username: Username, Password: "wrong"})
+
+	var authErr *alienvault.AuthError
+	assert.True(t, errors.As(client.Authenticate(), &authErr))
+}
+
+func TestServerClientCredentials(t *testing.T) {
+
+	server := NewServer(WithoutV1Sensors())
+	defer server.Close()
+
+	added := server.AddSensor(Sensor{Name: "sensor", Type: "AWS", Version: "6.0.0", LastConnected: 1577934245})
+
+	client := newTestClient(server, alienvault.Credentials{ClientID: ClientID, ClientSecret: ClientSecret})
+
+	sensor, err := client.GetSensor(added.V2ID)
+	require.Nil(t, err)
+	assert.Equal(t, "sensor", sensor.Name)
+	assert.Equal(t, "AWS", sensor.Type)
+	assert.Equal(t, "6.0.0", sensor.Version)
+	assert.Equal(t, int64(1577934245), sensor.LastConnected)
+
+	// jobs are only available to a cookie session
+	_, err = client.GetAWSBucketJobs()
+	require.NotNil(t, err)
+}
+
+func TestServerFail(t *testing.T) {
+
+	server := NewServer()
+	defer server.Close()
+
+	client := newTestClient(server, alienvault.Credentials{Username: Username, Password: Password})
+	require.Nil(t, client.Authenticate())
+
+	// a single failure is retried, but two are not
+	server.Fail("GET", "/api/1.0/license", http.StatusInternalServerError, 1)
+	_, err := client.GetLicense()
+	require.Nil(t, err)
+
+	server.Fail("GET", "/api/1.0/license", http.StatusInternalServerError, 2)
+	_, err = client.GetLicense()
+	var apiErr *alienvault.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+}
+
+func TestServerLatency(t *testing.T) {
+
+	server := NewServer()
+	defer server.Close()
+
+	client := newTestClient(server, alienvault.Credentials{Username: Username, Password: Password})
+	require.Nil(t, client.Authenticate())
+
+	server.SetLatency(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	_, err := client.GetLicenseWithContext(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
@@ -0,0 +1,332 @@
+package avtest
+
+import (
+	"fmt"
+	"time"
+)
+
+// Sensor is a sensor held by the fake server. Sensors have both a v1 and a v2 ID, as in USM Anywhere.
+type Sensor struct {
+	V1ID          string `json:"uuid"`
+	V2ID          string `json:"id"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	Status        string `json:"status"`
+	SetupStatus   string `json:"setupStatus"`
+	Type          string `json:"type"`
+	Version       string `json:"version"`
+	LastConnected int64  `json:"lastConnected"`
+
+	onSetupComplete func(id string) // called once the setup of a sensor registered by an appliance is completed
+}
+
+// SensorKey is a key which can be used once to activate a sensor
+type SensorKey struct {
+	ID        string  `json:"id"`
+	CreatedAt int64   `json:"createdAt"`
+	ExpiresAt int64   `json:"expires"`
+	NodeID    *string `json:"nodeId"`
+}
+
+// License is the license returned by the fake server
+type License struct {
+	ControlNodeLimit int   `json:"controlNodesAllowed"`
+	SensorNodeLimit  int   `json:"sensorNodesAllowed"`
+	MonthlyStorageKB int64 `json:"monthlyKBStorage"`
+	Expiration       int64 `json:"expiration"`
+}
+
+// Job is a scheduled job, kept exactly as it was sent by the client apart from the uuid which the server assigns
+type Job map[string]interface{}
+
+// UUID returns the ID of the job
+func (job Job) UUID() string {
+	uuid, _ := job["uuid"].(string)
+	return uuid
+}
+
+// defaultLicense allows a handful of sensors and expires well after any test run
+func defaultLicense() License {
+	return License{
+		ControlNodeLimit: 1,
+		SensorNodeLimit:  5,
+		MonthlyStorageKB: 1024 * 1024,
+		Expiration:       time.Now().Add(time.Hour * 24 * 365).Unix(),
+	}
+}
+
+// nextID returns a new ID with the given prefix, unique within the server
+func (s *Server) nextID(prefix string) string {
+	s.ids++
+	return fmt.Sprintf("%s-%08d", prefix, s.ids)
+}
+
+// AddSensor stores a sensor, as if it had been registered by an appliance, and returns it. Missing IDs are generated,
+// and the status and setup status default to "Ready" and "Complete".
+func (s *Server) AddSensor(sensor Sensor) Sensor {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if sensor.V1ID == "" {
+		sensor.V1ID = s.nextID("sensor-v1")
+	}
+	if sensor.V2ID == "" {
+		sensor.V2ID = s.nextID("sensor-v2")
+	}
+	if sensor.Status == "" {
+		sensor.Status = "Ready"
+	}
+	if sensor.SetupStatus == "" {
+		sensor.SetupStatus = "Complete"
+	}
+
+	s.sensors = append(s.sensors, &sensor)
+	return sensor
+}
+
+// Sensor returns the sensor with the given v1 or v2 ID
+func (s *Server) Sensor(id string) (Sensor, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if sensor := s.findSensor(id); sensor != nil {
+		return *sensor, true
+	}
+	return Sensor{}, false
+}
+
+// Sensors returns all sensors
+func (s *Server) Sensors() []Sensor {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sensors := make([]Sensor, 0, len(s.sensors))
+	for _, sensor := range s.sensors {
+		sensors = append(sensors, *sensor)
+	}
+	return sensors
+}
+
+// SetSensorStatus changes the status of a sensor, for example to "Connection lost"
+func (s *Server) SetSensorStatus(id string, status string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if sensor := s.findSensor(id); sensor != nil {
+		sensor.Status = status
+	}
+}
+
+func (s *Server) findSensor(id string) *Sensor {
+	for _, sensor := range s.sensors {
+		if sensor.V1ID == id || sensor.V2ID == id {
+			return sensor
+		}
+	}
+	return nil
+}
+
+func (s *Server) deleteSensor(id string) bool {
+	for i, sensor := range s.sensors {
+		if sensor.V1ID == id || sensor.V2ID == id {
+			s.sensors = append(s.sensors[:i], s.sensors[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// registerSensor stores a sensor registered by an appliance using the given key, which is marked as used by the sensor
+func (s *Server) registerSensor(keyID string, sensor Sensor) Sensor {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sensor.V1ID = s.nextID("sensor-v1")
+	sensor.V2ID = s.nextID("sensor-v2")
+	s.sensors = append(s.sensors, &sensor)
+
+	for _, key := range s.keys {
+		if key.ID == keyID {
+			nodeID := sensor.V1ID
+			key.NodeID = &nodeID
+		}
+	}
+
+	return sensor
+}
+
+// ActivateSensorKey registers a new sensor with the given key, as if an appliance had been activated with it, and
+// returns the sensor
+func (s *Server) ActivateSensorKey(id string) Sensor {
+	return s.registerSensor(id, Sensor{Name: "sensor", Status: "Ready", SetupStatus: "Complete"})
+}
+
+// AddSensorKey creates an unused sensor key and returns it
+func (s *Server) AddSensorKey() SensorKey {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.createSensorKey()
+}
+
+func (s *Server) createSensorKey() SensorKey {
+	now := time.Now()
+	key := SensorKey{
+		ID:        s.nextID("key"),
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(time.Hour * 24).Unix(),
+	}
+	s.keys = append(s.keys, &key)
+	return key
+}
+
+// SensorKeys returns all sensor keys
+func (s *Server) SensorKeys() []SensorKey {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	keys := make([]SensorKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, *key)
+	}
+	return keys
+}
+
+// checkSensorKey returns an error unless the key exists and can still be used to activate a sensor
+func (s *Server) checkSensorKey(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, key := range s.keys {
+		if key.ID != id {
+			continue
+		}
+		if key.NodeID != nil {
+			return fmt.Errorf("sensor key %s has already been used", id)
+		}
+		if time.Now().Unix() > key.ExpiresAt {
+			return fmt.Errorf("sensor key %s has expired", id)
+		}
+		return nil
+	}
+	return fmt.Errorf("sensor key %s does not exist", id)
+}
+
+// unusedSensorKeys returns the number of keys which have not been used to activate a sensor yet
+func (s *Server) unusedSensorKeys() int {
+	count := 0
+	for _, key := range s.keys {
+		if key.NodeID == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// ExpireSensorKey makes a sensor key expire, as if it had been created long ago
+func (s *Server) ExpireSensorKey(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, key := range s.keys {
+		if key.ID == id {
+			key.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+		}
+	}
+}
+
+// RemoveSensorKey deletes a sensor key, as if it had been removed outside of the client
+func (s *Server) RemoveSensorKey(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.deleteSensorKey(id)
+}
+
+func (s *Server) deleteSensorKey(id string) bool {
+	for i, key := range s.keys {
+		if key.ID == id {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// AddJob stores a job, assigning it a uuid if it doesn't have one, and returns the uuid
+func (s *Server) AddJob(job Job) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.storeJob(job)
+}
+
+func (s *Server) storeJob(job Job) string {
+	if job.UUID() == "" {
+		job["uuid"] = s.nextID("job")
+	}
+	s.jobs = append(s.jobs, job)
+	return job.UUID()
+}
+
+// Job returns the job with the given uuid
+func (s *Server) Job(uuid string) (Job, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, job := range s.jobs {
+		if job.UUID() == uuid {
+			return copyJob(job), true
+		}
+	}
+	return nil, false
+}
+
+// Jobs returns all jobs
+func (s *Server) Jobs() []Job {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, copyJob(job))
+	}
+	return jobs
+}
+
+func (s *Server) replaceJob(uuid string, job Job) bool {
+	for i := range s.jobs {
+		if s.jobs[i].UUID() == uuid {
+			job["uuid"] = uuid
+			s.jobs[i] = job
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) deleteJob(uuid string) bool {
+	for i := range s.jobs {
+		if s.jobs[i].UUID() == uuid {
+			s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func copyJob(job Job) Job {
+	c := Job{}
+	for k, v := range job {
+		c[k] = v
+	}
+	return c
+}
+
+// SetLicense replaces the license returned by the server
+func (s *Server) SetLicense(license License) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.license = license
+}
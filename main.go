@@ -2,14 +2,11 @@ package main
 
 import (
 	"github.com/form3tech-oss/terraform-provider-alienvault/alienvault"
-	"github.com/hashicorp/terraform/plugin"
-	"github.com/hashicorp/terraform/terraform"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
 )
 
 func main() {
 	plugin.Serve(&plugin.ServeOpts{
-		ProviderFunc: func() terraform.ResourceProvider {
-			return alienvault.Provider()
-		},
+		ProviderFunc: alienvault.Provider,
 	})
 }